@@ -0,0 +1,7 @@
+-- anonymized customers keep their row as a placeholder identity so
+-- transaction_main.customer_id stays linked for bookkeeping
+alter table cust_data
+	add column anonymized_at timestamptz;
+
+create index if not exists transaction_main_customer_id_idx
+	on transaction_main (customer_id, transaction_time);
@@ -0,0 +1,26 @@
+alter table cust_data
+	add column birth_date date;
+
+create table campaign_data (
+	id             bigserial primary key,
+	name           text not null,
+	campaign_type  text not null,
+	target_days    integer not null,
+	voucher_code   text not null unique,
+	voucher_detail text,
+	valid_until    timestamptz,
+	created_by     text not null default '',
+	created_at     timestamptz not null default now()
+);
+
+create table campaign_target (
+	campaign_id             bigint not null references campaign_data (id),
+	customer_id             bigint not null references cust_data (id),
+	contacted_at            timestamptz,
+	redeemed_at             timestamptz,
+	redeemed_transaction_id bigint references transaction_main (id),
+	primary key (campaign_id, customer_id)
+);
+
+create index campaign_target_customer_id_idx
+	on campaign_target (customer_id);
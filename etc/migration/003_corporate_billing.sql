@@ -0,0 +1,49 @@
+alter table cust_data
+	add column is_corporate boolean not null default false;
+
+create table product_customer_price (
+	customer_id         bigint not null references cust_data (id),
+	product_id          bigint not null references product_data (id),
+	price_standard      numeric not null,
+	price_express_today numeric not null,
+	price_express_tmr   numeric not null,
+	primary key (customer_id, product_id)
+);
+
+create table invoice_counter (
+	prefix   text primary key,
+	last_seq bigint not null
+);
+
+create table invoice_main (
+	id             bigserial primary key,
+	invoice_number text not null unique,
+	customer_id    bigint not null references cust_data (id),
+	period_start   timestamptz not null,
+	period_end     timestamptz not null,
+	issued_at      timestamptz not null default now(),
+	total          numeric not null,
+	paid           numeric not null default 0
+);
+
+create index invoice_main_customer_id_idx
+	on invoice_main (customer_id);
+
+create table invoice_line (
+	invoice_id     bigint not null references invoice_main (id),
+	transaction_id bigint not null unique references transaction_main (id),
+	amount         numeric not null,
+	primary key (invoice_id, transaction_id)
+);
+
+create table invoice_payment (
+	id             bigserial primary key,
+	invoice_id     bigint not null references invoice_main (id),
+	amount         numeric not null,
+	payment_method text not null,
+	received_by    text not null default '',
+	paid_at        timestamptz not null default now()
+);
+
+alter table transaction_main
+	add column invoice_id bigint references invoice_main (id);
@@ -0,0 +1,7 @@
+alter table cust_data
+	add column pay_later    boolean not null default false,
+	add column credit_limit numeric not null default 0,
+	add column blacklisted  boolean not null default false;
+
+alter table transaction_main
+	add column credit_approved_by text;
@@ -0,0 +1,26 @@
+-- every consent change is kept, cust_consent holds the latest change per channel
+create table cust_consent_history (
+	id          bigserial primary key,
+	customer_id bigint not null references cust_data (id),
+	channel     text not null,
+	granted     boolean not null,
+	method      text not null,
+	recorded_by text not null default '',
+	recorded_at timestamptz not null default now()
+);
+
+create index cust_consent_history_customer_id_idx
+	on cust_consent_history (customer_id, recorded_at);
+
+create table cust_consent (
+	customer_id bigint not null references cust_data (id),
+	channel     text not null,
+	granted     boolean not null,
+	method      text not null,
+	recorded_by text not null default '',
+	recorded_at timestamptz not null,
+	primary key (customer_id, channel)
+);
+
+alter table campaign_data
+	add column channel text not null default 'whatsapp';
@@ -0,0 +1,4 @@
+-- guards against concurrent creation of two active products with the same name
+create unique index if not exists product_data_active_name_idx
+	on product_data (lower(product_name))
+	where active = true;
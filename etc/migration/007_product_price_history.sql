@@ -0,0 +1,15 @@
+create table product_price_history (
+	id                  bigserial primary key,
+	product_id          bigint not null references product_data (id),
+	price_standard      numeric not null,
+	price_express_today numeric not null,
+	price_express_tmr   numeric not null,
+	effective_from      timestamptz not null,
+	effective_until     timestamptz,
+	unique (product_id, effective_from)
+);
+
+-- prices in use before the history existed are treated as effective since the epoch
+insert into product_price_history (product_id, price_standard, price_express_today, price_express_tmr, effective_from)
+select id, price_standard, price_express_today, price_express_tmr, '1970-01-01 00:00:00+00'
+from product_data;
@@ -0,0 +1,37 @@
+create table promotion_data (
+	id               bigserial primary key,
+	name             text not null,
+	promotion_type   text not null,
+	value            numeric not null default 0,
+	max_discount     numeric not null default 0,
+	min_spend        numeric not null default 0,
+	product_name     text,
+	buy_quantity     numeric not null default 0,
+	free_quantity    numeric not null default 0,
+	coupon_code      text unique,
+	usage_limit      integer not null default 0,
+	usage_count      integer not null default 0,
+	valid_from       timestamptz,
+	valid_until      timestamptz,
+	happy_hour_start text,
+	happy_hour_end   text,
+	active           boolean not null default true,
+	created_by       text not null default '',
+	created_at       timestamptz not null default now()
+);
+
+alter table transaction_main
+	add column discount numeric not null default 0,
+	add column coupon_code text;
+
+create table transaction_discount (
+	id             bigserial primary key,
+	transaction_id bigint not null references transaction_main (id),
+	promotion_id   bigint not null references promotion_data (id),
+	name           text not null,
+	product_name   text,
+	amount         numeric not null
+);
+
+create index transaction_discount_transaction_id_idx
+	on transaction_discount (transaction_id);
@@ -0,0 +1,27 @@
+create table product_addon (
+	id         bigserial primary key,
+	product_id bigint not null references product_data (id),
+	addon_name text not null,
+	price_type text not null,
+	price      numeric not null,
+	active     boolean not null default true
+);
+
+create index product_addon_product_id_idx
+	on product_addon (product_id);
+
+create table transaction_detail_addon (
+	id                    bigserial primary key,
+	transaction_detail_id bigint not null references transaction_detail (id),
+	addon_id              bigint not null references product_addon (id),
+	addon_name            text not null,
+	price_type            text not null,
+	price                 numeric not null,
+	subtotal              numeric not null
+);
+
+create index transaction_detail_addon_detail_id_idx
+	on transaction_detail_addon (transaction_detail_id);
+
+create index transaction_main_transaction_time_idx
+	on transaction_main (transaction_time);
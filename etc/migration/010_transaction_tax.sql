@@ -0,0 +1,23 @@
+alter table transaction_main
+	add column outlet_code text not null default '',
+	add column subtotal numeric not null default 0,
+	add column tax numeric not null default 0,
+	add column charge numeric not null default 0;
+
+-- transactions before the breakdown had no tax or charge
+update transaction_main set
+	subtotal = grand_total + discount;
+
+create table transaction_tax (
+	id             bigserial primary key,
+	transaction_id bigint not null references transaction_main (id),
+	name           text not null,
+	kind           text not null,
+	rate           numeric not null,
+	inclusive      boolean not null,
+	base           numeric not null,
+	amount         numeric not null
+);
+
+create index transaction_tax_transaction_id_idx
+	on transaction_tax (transaction_id);
@@ -0,0 +1,2 @@
+alter table transaction_main
+	add column rounding numeric not null default 0;
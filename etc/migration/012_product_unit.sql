@@ -0,0 +1,14 @@
+alter table product_data
+	add column unit          text not null default 'kg',
+	add column category      text not null default 'kiloan',
+	add column min_quantity  numeric not null default 0,
+	add column quantity_step numeric not null default 0;
+
+update product_data set
+	unit='piece',
+	category='satuan'
+where
+	is_satuan=true;
+
+create index product_data_category_idx
+	on product_data (category);
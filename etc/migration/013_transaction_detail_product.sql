@@ -0,0 +1,21 @@
+alter table transaction_detail
+	add column product_id bigint references product_data (id);
+
+-- lines are matched to the product with the same name, an active product is preferred over
+-- retired ones and the newest retired product wins when a name was reused
+update transaction_detail d set
+	product_id=p.id
+from (
+	select distinct on (lower(product_name))
+		id,
+		lower(product_name) as name
+	from
+		product_data
+	order by
+		lower(product_name), active desc, id desc
+) p
+where
+	d.product_id is null and lower(d.product_name)=p.name;
+
+create index transaction_detail_product_id_idx
+	on transaction_detail (product_id);
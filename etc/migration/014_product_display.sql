@@ -0,0 +1,6 @@
+alter table product_data
+	add column sort_order     integer not null default 0,
+	add column color          text not null default '',
+	add column icon           text not null default '',
+	add column image_path     text,
+	add column thumbnail_path text;
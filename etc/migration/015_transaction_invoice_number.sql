@@ -0,0 +1,16 @@
+-- transaction ids used to be sent by the POS tablets, they are assigned by the database now
+create sequence if not exists transaction_main_id_seq owned by transaction_main.id;
+
+select setval('transaction_main_id_seq', coalesce((select max(id) from transaction_main), 0) + 1, false);
+
+alter table transaction_main
+	alter column id set default nextval('transaction_main_id_seq');
+
+create table transaction_counter (
+	prefix   text primary key,
+	last_seq bigint not null
+);
+
+-- older transactions keep a null invoice number
+alter table transaction_main
+	add column invoice_number text unique;
@@ -0,0 +1,9 @@
+alter table transaction_main
+	add column date_ready     timestamptz,
+	add column handed_over_by text;
+
+-- orders picked up before ready dates were recorded were ready by then
+update transaction_main set
+	date_ready=date_taken
+where
+	date_taken is not null;
@@ -0,0 +1,33 @@
+alter table transaction_main
+	add column status text not null default 'received';
+
+update transaction_main set
+	status=case when date_taken is not null then 'picked_up' else 'ready' end
+where
+	date_ready is not null;
+
+create index transaction_main_status_idx
+	on transaction_main (status);
+
+create table transaction_status_history (
+	id             bigserial primary key,
+	transaction_id bigint not null references transaction_main (id),
+	from_status    text,
+	to_status      text not null,
+	actor          text not null,
+	note           text,
+	changed_at     timestamptz not null default now()
+);
+
+create index transaction_status_history_transaction_id_idx
+	on transaction_status_history (transaction_id);
+
+-- existing orders start their history with the status they have now
+insert into transaction_status_history (transaction_id, to_status, actor, changed_at)
+select
+	id,
+	status,
+	cashier_name,
+	coalesce(date_taken, date_ready, transaction_time)
+from
+	transaction_main;
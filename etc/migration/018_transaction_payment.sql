@@ -0,0 +1,26 @@
+create table transaction_payment (
+	id             bigserial primary key,
+	transaction_id bigint not null references transaction_main (id),
+	amount         numeric not null,
+	method         text not null,
+	reference      text,
+	cashier_name   text not null,
+	paid_at        timestamptz not null default now()
+);
+
+create index transaction_payment_transaction_id_idx
+	on transaction_payment (transaction_id);
+
+-- paid amounts recorded before the ledger become a single payment, pickup and invoice
+-- payments were added to them so their split is unknown
+insert into transaction_payment (transaction_id, amount, method, cashier_name, paid_at)
+select
+	id,
+	paid,
+	payment_method,
+	cashier_name,
+	transaction_time
+from
+	transaction_main
+where
+	paid > 0;
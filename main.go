@@ -11,6 +11,15 @@ import (
 
 	"github.com/jmoiron/sqlx"
 
+	"github.com/corneliusdavid97/laundry-go/src/billing"
+	bill_handler "github.com/corneliusdavid97/laundry-go/src/billing/handler"
+	bill_svc "github.com/corneliusdavid97/laundry-go/src/billing/service"
+	bill_store "github.com/corneliusdavid97/laundry-go/src/billing/store"
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+	camp_handler "github.com/corneliusdavid97/laundry-go/src/campaign/handler"
+	camp_svc "github.com/corneliusdavid97/laundry-go/src/campaign/service"
+	camp_store "github.com/corneliusdavid97/laundry-go/src/campaign/store"
+	"github.com/corneliusdavid97/laundry-go/src/config"
 	"github.com/corneliusdavid97/laundry-go/src/customer"
 	cust_handler "github.com/corneliusdavid97/laundry-go/src/customer/handler"
 	cust_svc "github.com/corneliusdavid97/laundry-go/src/customer/service"
@@ -19,6 +28,14 @@ import (
 	prod_handler "github.com/corneliusdavid97/laundry-go/src/product/handler"
 	prod_svc "github.com/corneliusdavid97/laundry-go/src/product/service"
 	prod_store "github.com/corneliusdavid97/laundry-go/src/product/store"
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
+	promo_handler "github.com/corneliusdavid97/laundry-go/src/promotion/handler"
+	promo_svc "github.com/corneliusdavid97/laundry-go/src/promotion/service"
+	promo_store "github.com/corneliusdavid97/laundry-go/src/promotion/store"
+	"github.com/corneliusdavid97/laundry-go/src/report"
+	report_handler "github.com/corneliusdavid97/laundry-go/src/report/handler"
+	report_svc "github.com/corneliusdavid97/laundry-go/src/report/service"
+	report_store "github.com/corneliusdavid97/laundry-go/src/report/store"
 	"github.com/corneliusdavid97/laundry-go/src/transaction"
 	trans_handler "github.com/corneliusdavid97/laundry-go/src/transaction/handler"
 	trans_svc "github.com/corneliusdavid97/laundry-go/src/transaction/service"
@@ -27,6 +44,7 @@ import (
 	user_handler "github.com/corneliusdavid97/laundry-go/src/user/handler"
 	user_svc "github.com/corneliusdavid97/laundry-go/src/user/service"
 	user_store "github.com/corneliusdavid97/laundry-go/src/user/store"
+	"github.com/corneliusdavid97/laundry-go/tools/filestore"
 	"github.com/corneliusdavid97/laundry-go/tools/postgresql"
 )
 
@@ -40,6 +58,13 @@ func main() {
 	defer cancel()
 
 	var err error
+	var authHandler *user_handler.HTTPHandler
+
+	// config init
+	err = config.InitConfig(basepath)
+	if err != nil {
+		log.Fatalf("Failed to init config, err: %s", err.Error())
+	}
 
 	// postgresql init
 	err = postgresql.InitPostgresqlConfig(ctx, basepath)
@@ -54,12 +79,12 @@ func main() {
 		})
 		svc := user_svc.NewService(store)
 		user.Init(svc)
-		userHTTPHandler := user_handler.NewHandler(svc, user_handler.Config{
+		authHandler = user_handler.NewHandler(svc, user_handler.Config{
 			Timeout: time.Duration(3) * time.Second,
 		})
 
 		// handle HTTP request
-		http.HandleFunc("/auth", userHTTPHandler.HandleAuthUser)
+		http.HandleFunc("/auth", authHandler.HandleAuthUser)
 	}
 
 	// customer module
@@ -67,7 +92,7 @@ func main() {
 		store := cust_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
 			return postgresql.GetDB(dbName, replication)
 		})
-		svc := cust_svc.NewService(store)
+		svc := cust_svc.NewService(store, config.Get().Consent)
 		customer.Init(svc)
 		userHTTPHandler := cust_handler.NewHandler(svc, cust_handler.Config{
 			Timeout: time.Duration(3) * time.Second,
@@ -76,6 +101,13 @@ func main() {
 		// handle HTTP request
 		http.HandleFunc("/customer/all", userHTTPHandler.HandleGetAllActiveCustomer)
 		http.HandleFunc("/customer/insert", userHTTPHandler.HandleInsertNewCustomer)
+		http.HandleFunc("/customer/export", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleExportCustomerData))
+		http.HandleFunc("/customer/anonymize", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleAnonymizeCustomer))
+		http.HandleFunc("/customer/corporate", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleSetCorporate))
+		http.HandleFunc("/customer/credit", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleSetCreditSettings))
+		http.HandleFunc("/customer/consent", userHTTPHandler.HandleGetConsents)
+		http.HandleFunc("/customer/consent/record", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleRecordConsent))
+		http.HandleFunc("/customer/optout", userHTTPHandler.HandleOptOut)
 	}
 
 	// product module
@@ -83,14 +115,69 @@ func main() {
 		store := prod_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
 			return postgresql.GetDB(dbName, replication)
 		})
-		svc := prod_svc.NewService(store)
+		files, err := newFileStore(basepath, config.Get().Files)
+		if err != nil {
+			log.Fatalf("Failed to init file store, err: %s", err.Error())
+		}
+		productCfg := config.Get().Product
+		svc := prod_svc.NewService(store, files, prod_svc.Config{
+			CacheTTL:      time.Duration(productCfg.CacheSeconds) * time.Second,
+			NotifyChanges: productCfg.NotifyChanges,
+		})
+		if productCfg.NotifyChanges {
+			err = postgresql.Listen("db_main", prod_svc.CatalogChannel, svc.InvalidateCache)
+			if err != nil {
+				log.Fatalf("Failed to listen for product catalog changes, err: %s", err.Error())
+			}
+		}
 		product.Init(svc)
 		userHTTPHandler := prod_handler.NewHandler(svc, prod_handler.Config{
 			Timeout: time.Duration(3) * time.Second,
 		})
 
 		// handle HTTP request
-		http.HandleFunc("/product/all", userHTTPHandler.HandleGetAllActiveProduct)
+		// customer prices are negotiated per corporate customer, only admins may list them
+		customerProducts := authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetAllActiveProduct)
+		http.HandleFunc("/product/all", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("customer_id") != "" {
+				customerProducts(w, r)
+				return
+			}
+			userHTTPHandler.HandleGetAllActiveProduct(w, r)
+		})
+		http.HandleFunc("/product", userHTTPHandler.HandleGetProductByID)
+		http.HandleFunc("/product/new", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleAddNewProduct))
+		http.HandleFunc("/product/update", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleUpdateProduct))
+		http.HandleFunc("/product/deactivate", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleDeactivateProduct))
+		http.HandleFunc("/product/image", userHTTPHandler.HandleGetProductImage)
+		http.HandleFunc("/product/image/upload", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleUploadProductImage))
+		http.HandleFunc("/product/price", userHTTPHandler.HandleGetPriceAsOf)
+		http.HandleFunc("/product/price/history", userHTTPHandler.HandleGetPriceHistory)
+		http.HandleFunc("/product/price/schedule", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleSchedulePriceChange))
+		http.HandleFunc("/product/price/adjust", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleAdjustPrices))
+		http.HandleFunc("/product/addons", userHTTPHandler.HandleGetAddons)
+		http.HandleFunc("/product/addon/new", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleAddAddon))
+		http.HandleFunc("/product/addon/deactivate", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleDeactivateAddon))
+		http.HandleFunc("/product/customer-price", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetCustomerPrices))
+		http.HandleFunc("/product/customer-price/set", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleSetCustomerPrice))
+		http.HandleFunc("/product/customer-price/delete", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleDeleteCustomerPrice))
+	}
+
+	// promotion module
+	{
+		store := promo_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
+			return postgresql.GetDB(dbName, replication)
+		})
+		svc := promo_svc.NewService(store)
+		promotion.Init(svc)
+		userHTTPHandler := promo_handler.NewHandler(svc, promo_handler.Config{
+			Timeout: time.Duration(3) * time.Second,
+		})
+
+		// handle HTTP request
+		http.HandleFunc("/promotion/all", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetAllPromotions))
+		http.HandleFunc("/promotion/new", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleNewPromotion))
+		http.HandleFunc("/promotion/deactivate", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleDeactivatePromotion))
 	}
 
 	// transaction module
@@ -98,7 +185,13 @@ func main() {
 		store := trans_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
 			return postgresql.GetDB(dbName, replication)
 		})
-		svc := trans_svc.NewService(store)
+		svc := trans_svc.NewService(store, trans_svc.Config{
+			Credit:     config.Get().Credit,
+			Turnaround: config.Get().Turnaround,
+			Outlet:     config.Get().Outlet,
+			Tax:        config.Get().Tax,
+			Rounding:   config.Get().Rounding,
+		})
 		transaction.Init(svc)
 		userHTTPHandler := trans_handler.NewHandler(svc, trans_handler.Config{
 			Timeout: time.Duration(3) * time.Second,
@@ -106,7 +199,69 @@ func main() {
 
 		// handle HTTP request
 		http.HandleFunc("/transaction/new", userHTTPHandler.HandleNewTransaction)
+		http.HandleFunc("/transaction/quote", userHTTPHandler.HandleQuote)
 		http.HandleFunc("/transaction", userHTTPHandler.GetTransactionDataByID)
+		http.HandleFunc("/transaction/ready", userHTTPHandler.HandleMarkReady)
+		http.HandleFunc("/transaction/pickup", userHTTPHandler.HandlePickup)
+		http.HandleFunc("/transaction/payment", userHTTPHandler.HandleAddPayment)
+		http.HandleFunc("/transaction/status", userHTTPHandler.HandleChangeStatus)
+		http.HandleFunc("/transaction/status/history", userHTTPHandler.HandleGetStatusHistory)
+		http.HandleFunc("/transaction/list", userHTTPHandler.HandleGetTransactions)
+	}
+
+	// billing module
+	{
+		store := bill_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
+			return postgresql.GetDB(dbName, replication)
+		})
+		svc := bill_svc.NewService(store)
+		billing.Init(svc)
+		userHTTPHandler := bill_handler.NewHandler(svc, bill_handler.Config{
+			Timeout: time.Duration(30) * time.Second,
+		})
+
+		// handle HTTP request
+		http.HandleFunc("/billing/invoice/run", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleRunMonthlyInvoicing))
+		http.HandleFunc("/billing/invoice", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetInvoice))
+		http.HandleFunc("/billing/invoice/customer", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetCustomerInvoices))
+		http.HandleFunc("/billing/invoice/pay", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandlePayInvoice))
+	}
+
+	// campaign module
+	{
+		store := camp_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
+			return postgresql.GetDB(dbName, replication)
+		})
+		svc := camp_svc.NewService(store)
+		campaign.Init(svc)
+		userHTTPHandler := camp_handler.NewHandler(svc, camp_handler.Config{
+			Timeout: time.Duration(10) * time.Second,
+		})
+
+		// handle HTTP request
+		http.HandleFunc("/campaign/all", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetAllCampaigns))
+		http.HandleFunc("/campaign/new", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleNewCampaign))
+		http.HandleFunc("/campaign/targets", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetCampaignTargets))
+		http.HandleFunc("/campaign/contacted", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleMarkContacted))
+		// cashiers redeem vouchers at the counter when they take the order
+		http.HandleFunc("/campaign/redeem", authHandler.RequireAnyRole([]user.RoleID{user.RoleCashier, user.RoleAdmin}, userHTTPHandler.HandleRedeemVoucher))
+	}
+
+	// report module
+	{
+		store := report_store.NewStore(func(dbName, replication string) (*sqlx.DB, error) {
+			return postgresql.GetDB(dbName, replication)
+		})
+		svc := report_svc.NewService(store, config.Get().Report.RFM)
+		report.Init(svc)
+		userHTTPHandler := report_handler.NewHandler(svc, report_handler.Config{
+			Timeout: time.Duration(30) * time.Second,
+		})
+
+		// handle HTTP request
+		http.HandleFunc("/report/rfm", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetRFMReport))
+		http.HandleFunc("/report/sales", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetSalesReport))
+		http.HandleFunc("/report/tax", authHandler.RequireRole(user.RoleAdmin, userHTTPHandler.HandleGetTaxSummary))
 	}
 
 	port := 4321
@@ -116,3 +271,19 @@ func main() {
 		log.Fatalln(err)
 	}
 }
+
+// newFileStore creates the file store selected by the config
+func newFileStore(basepath string, cfg config.FilesConfig) (filestore.Store, error) {
+	switch cfg.Driver {
+	case "", "local":
+		dir := cfg.LocalDir
+		if dir == "" {
+			dir = "files"
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(basepath, dir)
+		}
+		return filestore.NewLocal(dir), nil
+	}
+	return nil, fmt.Errorf("unknown file store driver %s", cfg.Driver)
+}
@@ -0,0 +1,82 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusUnpaid        InvoiceStatus = "unpaid"
+	InvoiceStatusPartiallyPaid InvoiceStatus = "partially_paid"
+	InvoiceStatusPaid          InvoiceStatus = "paid"
+)
+
+// Invoice consolidates the unpaid transactions of a corporate customer within a billing period
+type Invoice struct {
+	ID            int64
+	InvoiceNumber string
+	CustomerID    int64
+	CustomerName  string
+	PeriodStart   time.Time
+	PeriodEnd     time.Time
+	IssuedAt      time.Time
+	Total         money.Money
+	Paid          money.Money
+	Lines         []InvoiceLine
+	Payments      []Payment
+}
+
+// Status reports whether the invoice is settled
+func (i Invoice) Status() InvoiceStatus {
+	if i.Paid <= 0 {
+		return InvoiceStatusUnpaid
+	}
+	if i.Paid < i.Total {
+		return InvoiceStatusPartiallyPaid
+	}
+	return InvoiceStatusPaid
+}
+
+// InvoiceLine is a transaction billed on an invoice, Amount is its unpaid balance at invoicing time
+type InvoiceLine struct {
+	TransactionID   int64
+	TransactionTime time.Time
+	GrandTotal      money.Money
+	Amount          money.Money
+}
+
+type Payment struct {
+	ID            int64
+	InvoiceID     int64
+	Amount        money.Money
+	PaymentMethod string
+	ReceivedBy    string
+	PaidAt        time.Time
+}
+
+var ErrInvalidPeriod = errors.New("Invoicing period has not ended yet")
+var ErrInvalidPayment = errors.New("Invalid invoice payment")
+var ErrOverpayment = errors.New("Payment exceeds the remaining invoice balance")
+
+type Service interface {
+	RunMonthlyInvoicing(ctx context.Context, month time.Time) ([]Invoice, error)
+	GetInvoiceByID(ctx context.Context, ID int64) (Invoice, error)
+	GetInvoiceByNumber(ctx context.Context, invoiceNumber string) (Invoice, error)
+	GetInvoicesByCustomerID(ctx context.Context, customerID int64) ([]Invoice, error)
+	PayInvoice(ctx context.Context, payment Payment) (Invoice, error)
+}
+
+var defaultService Service
+
+func Init(s Service) {
+	defaultService = s
+}
+
+func GetService() Service {
+	return defaultService
+}
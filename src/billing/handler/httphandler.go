@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/billing"
+	"github.com/corneliusdavid97/laundry-go/src/user"
+	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/timer"
+)
+
+type HTTPHandler struct {
+	svc billing.Service
+	cfg Config
+}
+
+type Config struct {
+	Timeout time.Duration
+}
+
+type Invoice struct {
+	ID            int64         `json:"id"`
+	InvoiceNumber string        `json:"invoice_number"`
+	CustomerID    int64         `json:"customer_id"`
+	CustomerName  string        `json:"customer_name"`
+	PeriodStart   string        `json:"period_start"`
+	PeriodEnd     string        `json:"period_end"`
+	IssuedAt      string        `json:"issued_at"`
+	Total         money.Money   `json:"total"`
+	Paid          money.Money   `json:"paid"`
+	Status        string        `json:"status"`
+	Lines         []InvoiceLine `json:"lines,omitempty"`
+	Payments      []Payment     `json:"payments,omitempty"`
+}
+
+type InvoiceLine struct {
+	TransactionID   int64       `json:"transaction_id"`
+	TransactionTime string      `json:"transaction_time"`
+	GrandTotal      money.Money `json:"grand_total"`
+	Amount          money.Money `json:"amount"`
+}
+
+type Payment struct {
+	ID            int64       `json:"id"`
+	Amount        money.Money `json:"amount"`
+	PaymentMethod string      `json:"payment_method"`
+	ReceivedBy    string      `json:"received_by"`
+	PaidAt        string      `json:"paid_at"`
+}
+
+var errInvoiceParam = errors.New("Either id or number is required")
+
+func (h *HTTPHandler) HandleRunMonthlyInvoicing(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	month, err := time.ParseInLocation("2006-01", r.FormValue("month"), time.Local)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	invoices, err := h.svc.RunMonthlyInvoicing(ctx, month)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	res := make([]Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		res = append(res, parseInvoice(inv))
+	}
+
+	httputil.WriteDataResponse(w, res, len(res), t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleGetInvoice(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	var inv billing.Invoice
+	var err error
+	if number := r.FormValue("number"); len(number) > 0 {
+		inv, err = h.svc.GetInvoiceByNumber(ctx, number)
+	} else if sID := r.FormValue("id"); len(sID) > 0 {
+		id, parseErr := strconv.ParseInt(sID, 10, 64)
+		if parseErr != nil {
+			httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(parseErr)})
+			return
+		}
+		inv, err = h.svc.GetInvoiceByID(ctx, id)
+	} else {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(errInvoiceParam)})
+		return
+	}
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteDataResponse(w, parseInvoice(inv), 1, t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleGetCustomerInvoices(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	customerID, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	invoices, err := h.svc.GetInvoicesByCustomerID(ctx, customerID)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	res := make([]Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		res = append(res, parseInvoice(inv))
+	}
+
+	httputil.WriteDataResponse(w, res, len(res), t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandlePayInvoice(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	invoiceID, err := strconv.ParseInt(r.FormValue("invoice_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+	amount, err := money.Parse(r.FormValue("amount"))
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	payment := billing.Payment{
+		InvoiceID:     invoiceID,
+		Amount:        amount,
+		PaymentMethod: r.FormValue("payment_method"),
+	}
+	if u, ok := user.FromContext(r.Context()); ok {
+		payment.ReceivedBy = u.Username
+	}
+
+	inv, err := h.svc.PayInvoice(ctx, payment)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteDataResponse(w, parseInvoice(inv), 1, t.GetElapsedTime())
+}
+
+func parseError(err error) httputil.ErrorResponse {
+	status := http.StatusInternalServerError
+	switch err {
+	case billing.ErrInvalidPeriod, billing.ErrInvalidPayment:
+		status = http.StatusBadRequest
+	case billing.ErrOverpayment:
+		status = http.StatusConflict
+	case sql.ErrNoRows:
+		status = http.StatusNotFound
+	}
+	return httputil.NewErrorResponse(status, err)
+}
+
+func parseInvoice(inv billing.Invoice) Invoice {
+	res := Invoice{
+		ID:            inv.ID,
+		InvoiceNumber: inv.InvoiceNumber,
+		CustomerID:    inv.CustomerID,
+		CustomerName:  inv.CustomerName,
+		PeriodStart:   inv.PeriodStart.Format("2006-01-02"),
+		PeriodEnd:     inv.PeriodEnd.Format("2006-01-02"),
+		IssuedAt:      inv.IssuedAt.Format("2006-01-02 15:04:05"),
+		Total:         inv.Total,
+		Paid:          inv.Paid,
+		Status:        string(inv.Status()),
+	}
+	for _, l := range inv.Lines {
+		res.Lines = append(res.Lines, InvoiceLine{
+			TransactionID:   l.TransactionID,
+			TransactionTime: l.TransactionTime.Format("2006-01-02 15:04:05"),
+			GrandTotal:      l.GrandTotal,
+			Amount:          l.Amount,
+		})
+	}
+	for _, p := range inv.Payments {
+		res.Payments = append(res.Payments, Payment{
+			ID:            p.ID,
+			Amount:        p.Amount,
+			PaymentMethod: p.PaymentMethod,
+			ReceivedBy:    p.ReceivedBy,
+			PaidAt:        p.PaidAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return res
+}
+
+func NewHandler(svc billing.Service, cfg Config) *HTTPHandler {
+	return &HTTPHandler{
+		svc: svc,
+		cfg: cfg,
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/billing"
+	"github.com/corneliusdavid97/laundry-go/src/transaction"
+)
+
+type Service struct {
+	store Store
+}
+
+type Store interface {
+	CreateInvoices(ctx context.Context, periodStart, periodEnd time.Time, numberPrefix string) ([]int64, error)
+	GetInvoiceByID(ctx context.Context, ID int64) (billing.Invoice, error)
+	GetInvoiceIDByNumber(ctx context.Context, invoiceNumber string) (int64, error)
+	GetInvoicesByCustomerID(ctx context.Context, customerID int64) ([]billing.Invoice, error)
+	AddInvoicePayment(ctx context.Context, payment billing.Payment) error
+}
+
+// RunMonthlyInvoicing consolidates the unbilled transactions of every corporate customer
+// within the given month into one invoice per customer
+func (s *Service) RunMonthlyInvoicing(ctx context.Context, month time.Time) ([]billing.Invoice, error) {
+	periodStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	if periodEnd.After(time.Now()) {
+		return []billing.Invoice{}, billing.ErrInvalidPeriod
+	}
+
+	ids, err := s.store.CreateInvoices(ctx, periodStart, periodEnd, "INV-"+periodStart.Format("200601"))
+	if err != nil {
+		return []billing.Invoice{}, err
+	}
+
+	res := make([]billing.Invoice, 0, len(ids))
+	for _, id := range ids {
+		inv, err := s.store.GetInvoiceByID(ctx, id)
+		if err != nil {
+			return []billing.Invoice{}, err
+		}
+		res = append(res, inv)
+	}
+	return res, nil
+}
+
+func (s *Service) GetInvoiceByID(ctx context.Context, ID int64) (billing.Invoice, error) {
+	res, err := s.store.GetInvoiceByID(ctx, ID)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) GetInvoiceByNumber(ctx context.Context, invoiceNumber string) (billing.Invoice, error) {
+	id, err := s.store.GetInvoiceIDByNumber(ctx, invoiceNumber)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+	return s.GetInvoiceByID(ctx, id)
+}
+
+func (s *Service) GetInvoicesByCustomerID(ctx context.Context, customerID int64) ([]billing.Invoice, error) {
+	res, err := s.store.GetInvoicesByCustomerID(ctx, customerID)
+	if err != nil {
+		return []billing.Invoice{}, err
+	}
+	return res, nil
+}
+
+// PayInvoice records a full or partial payment of an invoice, the payment is spread over the
+// payment ledgers of the invoiced transactions so its method must be a transaction payment method
+func (s *Service) PayInvoice(ctx context.Context, payment billing.Payment) (billing.Invoice, error) {
+	if payment.Amount <= 0 || !transaction.PaymentMethod(payment.PaymentMethod).Valid() {
+		return billing.Invoice{}, billing.ErrInvalidPayment
+	}
+
+	err := s.store.AddInvoicePayment(ctx, payment)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+	return s.store.GetInvoiceByID(ctx, payment.InvoiceID)
+}
+
+func NewService(store Store) *Service {
+	return &Service{
+		store: store,
+	}
+}
@@ -0,0 +1,529 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/corneliusdavid97/laundry-go/src/billing"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+const queryGetUnbilledCustomers = `
+	select distinct
+		t.customer_id
+	from
+		transaction_main t
+		join cust_data c on c.id = t.customer_id
+	where
+		c.is_corporate=true
+		and t.invoice_id is null
+		and t.grand_total > t.paid
+		and t.status <> 'cancelled'
+		and t.transaction_time >= $1
+		and t.transaction_time < $2
+	order by
+		t.customer_id
+`
+
+const queryLockUnbilledTransactions = `
+	select
+		id,
+		grand_total - paid
+	from
+		transaction_main
+	where
+		customer_id=$1
+		and invoice_id is null
+		and grand_total > paid
+		and status <> 'cancelled'
+		and transaction_time >= $2
+		and transaction_time < $3
+	order by
+		transaction_time
+	for update
+`
+
+// the counter row lock serializes concurrent runs, so numbers stay gap-free as long as
+// the surrounding transaction commits
+const queryNextInvoiceSequence = `
+	insert into invoice_counter(
+		prefix,
+		last_seq
+	)values(
+		$1,
+		1
+	)
+	on conflict (prefix) do update set
+		last_seq=invoice_counter.last_seq + 1
+	returning last_seq
+`
+
+const queryInsertInvoice = `
+	insert into invoice_main(
+		invoice_number,
+		customer_id,
+		period_start,
+		period_end,
+		total
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5
+	)
+	returning id
+`
+
+const queryInsertInvoiceLines = `
+	insert into invoice_line(
+		invoice_id,
+		transaction_id,
+		amount
+	)
+	select
+		$1,
+		id,
+		grand_total - paid
+	from
+		transaction_main
+	where
+		id = any($2)
+`
+
+const queryMarkTransactionsBilled = `
+	update transaction_main set
+		invoice_id=$1
+	where
+		id = any($2)
+`
+
+const queryGetInvoiceByID = `
+	select
+		i.id,
+		i.invoice_number,
+		i.customer_id,
+		c.name,
+		i.period_start,
+		i.period_end,
+		i.issued_at,
+		i.total,
+		i.paid
+	from
+		invoice_main i
+		join cust_data c on c.id = i.customer_id
+	where
+		i.id=$1
+`
+
+const queryGetInvoiceIDByNumber = `
+	select
+		id
+	from
+		invoice_main
+	where
+		invoice_number=$1
+`
+
+const queryGetInvoicesByCustomerID = `
+	select
+		i.id,
+		i.invoice_number,
+		i.customer_id,
+		c.name,
+		i.period_start,
+		i.period_end,
+		i.issued_at,
+		i.total,
+		i.paid
+	from
+		invoice_main i
+		join cust_data c on c.id = i.customer_id
+	where
+		i.customer_id=$1
+	order by
+		i.period_start desc
+`
+
+const queryGetInvoiceLines = `
+	select
+		l.transaction_id,
+		t.transaction_time,
+		t.grand_total,
+		l.amount
+	from
+		invoice_line l
+		join transaction_main t on t.id = l.transaction_id
+	where
+		l.invoice_id=$1
+	order by
+		t.transaction_time
+`
+
+const queryGetInvoicePayments = `
+	select
+		id,
+		invoice_id,
+		amount,
+		payment_method,
+		received_by,
+		paid_at
+	from
+		invoice_payment
+	where
+		invoice_id=$1
+	order by
+		paid_at
+`
+
+// queryLockInvoice serializes payments of the same invoice
+const queryLockInvoice = `
+	select
+		total
+	from
+		invoice_main
+	where
+		id=$1
+	for update
+`
+
+const queryInsertInvoicePayment = `
+	insert into invoice_payment(
+		invoice_id,
+		amount,
+		payment_method,
+		received_by
+	)values(
+		$1,
+		$2,
+		$3,
+		$4
+	)
+`
+
+// querySetInvoicePaid derives the paid amount of an invoice from the unpaid balance of its
+// lines, so payments made directly to the invoiced transactions count as well
+const querySetInvoicePaid = `
+	update invoice_main set
+		paid=total - $2
+	where
+		id=$1
+`
+
+const queryLockInvoiceTransactions = `
+	select
+		t.id,
+		t.grand_total - t.paid
+	from
+		invoice_line l
+		join transaction_main t on t.id = l.transaction_id
+	where
+		l.invoice_id=$1
+		and t.grand_total > t.paid
+	order by
+		t.transaction_time
+	for update of t
+`
+
+// queryAddTransactionPayment records the part of an invoice payment allocated to a transaction
+// in its payment ledger, referenced by the invoice number
+const queryAddTransactionPayment = `
+	insert into transaction_payment(
+		transaction_id,
+		amount,
+		method,
+		reference,
+		cashier_name
+	)
+	select
+		$1,
+		$2,
+		$3,
+		invoice_number,
+		$4
+	from
+		invoice_main
+	where
+		id=$5
+`
+
+const queryRefreshTransactionPaid = `
+	update transaction_main set
+		paid=(select coalesce(sum(amount),0) from transaction_payment where transaction_id=$1)
+	where
+		id=$1
+`
+
+type Store struct {
+	getDB func(dbName, replication string) (*sqlx.DB, error)
+}
+
+// CreateInvoices creates one invoice per corporate customer with unbilled transactions
+// in the period and returns the new invoice ids
+func (s *Store) CreateInvoices(ctx context.Context, periodStart, periodEnd time.Time, numberPrefix string) ([]int64, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []int64{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return []int64{}, err
+	}
+
+	var customerIDs []int64
+	err = tx.SelectContext(ctx, &customerIDs, queryGetUnbilledCustomers, periodStart, periodEnd)
+	if err != nil {
+		tx.Rollback()
+		return []int64{}, err
+	}
+
+	res := make([]int64, 0, len(customerIDs))
+	for _, customerID := range customerIDs {
+		invoiceID, err := createCustomerInvoice(ctx, tx, customerID, periodStart, periodEnd, numberPrefix)
+		if err != nil {
+			tx.Rollback()
+			return []int64{}, err
+		}
+		if invoiceID != 0 {
+			res = append(res, invoiceID)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return []int64{}, err
+	}
+	return res, nil
+}
+
+func createCustomerInvoice(ctx context.Context, tx *sqlx.Tx, customerID int64, periodStart, periodEnd time.Time, numberPrefix string) (int64, error) {
+	rows, err := tx.QueryContext(ctx, queryLockUnbilledTransactions, customerID, periodStart, periodEnd)
+	if err != nil {
+		return 0, err
+	}
+	var transIDs []int64
+	var total money.Money
+	for rows.Next() {
+		var id int64
+		var amount money.Money
+		err = rows.Scan(&id, &amount)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		transIDs = append(transIDs, id)
+		total += amount
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	// billed by a concurrent run
+	if len(transIDs) == 0 {
+		return 0, nil
+	}
+
+	var seq int64
+	err = tx.QueryRowContext(ctx, queryNextInvoiceSequence, numberPrefix).Scan(&seq)
+	if err != nil {
+		return 0, err
+	}
+
+	var invoiceID int64
+	invoiceNumber := fmt.Sprintf("%s-%04d", numberPrefix, seq)
+	err = tx.QueryRowContext(ctx, queryInsertInvoice, invoiceNumber, customerID, periodStart, periodEnd, total).Scan(&invoiceID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertInvoiceLines, invoiceID, pq.Array(transIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMarkTransactionsBilled, invoiceID, pq.Array(transIDs))
+	if err != nil {
+		return 0, err
+	}
+	return invoiceID, nil
+}
+
+func (s *Store) GetInvoiceByID(ctx context.Context, ID int64) (billing.Invoice, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+
+	var inv billing.Invoice
+	err = db.QueryRowContext(ctx, queryGetInvoiceByID, ID).Scan(&inv.ID, &inv.InvoiceNumber, &inv.CustomerID, &inv.CustomerName, &inv.PeriodStart, &inv.PeriodEnd, &inv.IssuedAt, &inv.Total, &inv.Paid)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetInvoiceLines, ID)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line billing.InvoiceLine
+		err = rows.Scan(&line.TransactionID, &line.TransactionTime, &line.GrandTotal, &line.Amount)
+		if err != nil {
+			return billing.Invoice{}, err
+		}
+		inv.Lines = append(inv.Lines, line)
+	}
+
+	rows, err = db.QueryContext(ctx, queryGetInvoicePayments, ID)
+	if err != nil {
+		return billing.Invoice{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p billing.Payment
+		err = rows.Scan(&p.ID, &p.InvoiceID, &p.Amount, &p.PaymentMethod, &p.ReceivedBy, &p.PaidAt)
+		if err != nil {
+			return billing.Invoice{}, err
+		}
+		inv.Payments = append(inv.Payments, p)
+	}
+	return inv, nil
+}
+
+func (s *Store) GetInvoiceIDByNumber(ctx context.Context, invoiceNumber string) (int64, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = db.QueryRowContext(ctx, queryGetInvoiceIDByNumber, invoiceNumber).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *Store) GetInvoicesByCustomerID(ctx context.Context, customerID int64) ([]billing.Invoice, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []billing.Invoice{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetInvoicesByCustomerID, customerID)
+	if err != nil {
+		return []billing.Invoice{}, err
+	}
+	defer rows.Close()
+
+	res := make([]billing.Invoice, 0)
+	for rows.Next() {
+		var inv billing.Invoice
+		err = rows.Scan(&inv.ID, &inv.InvoiceNumber, &inv.CustomerID, &inv.CustomerName, &inv.PeriodStart, &inv.PeriodEnd, &inv.IssuedAt, &inv.Total, &inv.Paid)
+		if err != nil {
+			return []billing.Invoice{}, err
+		}
+		res = append(res, inv)
+	}
+	return res, rows.Err()
+}
+
+// AddInvoicePayment records the payment and settles the billed transactions oldest first, it fails
+// with ErrOverpayment when the payment exceeds what the billed transactions still owe
+func (s *Store) AddInvoicePayment(ctx context.Context, payment billing.Payment) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	var total money.Money
+	err = tx.QueryRowContext(ctx, queryLockInvoice, payment.InvoiceID).Scan(&total)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// the remaining balance is what the invoiced transactions still owe, some of them may have
+	// been paid directly since the invoice was issued
+	rows, err := tx.QueryContext(ctx, queryLockInvoiceTransactions, payment.InvoiceID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	type allocation struct {
+		transID int64
+		amount  money.Money
+	}
+	var allocations []allocation
+	var remaining money.Money
+	left := payment.Amount
+	for rows.Next() {
+		var a allocation
+		var unpaid money.Money
+		err = rows.Scan(&a.transID, &unpaid)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		remaining += unpaid
+		if left <= 0 {
+			continue
+		}
+		a.amount = money.Min(unpaid, left)
+		allocations = append(allocations, a)
+		left -= a.amount
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if left > 0 {
+		tx.Rollback()
+		return billing.ErrOverpayment
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertInvoicePayment, payment.InvoiceID, payment.Amount, payment.PaymentMethod, payment.ReceivedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, querySetInvoicePaid, payment.InvoiceID, remaining-payment.Amount)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, a := range allocations {
+		_, err = tx.ExecContext(ctx, queryAddTransactionPayment, a.transID, a.amount, payment.PaymentMethod, payment.ReceivedBy, payment.InvoiceID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.ExecContext(ctx, queryRefreshTransactionPaid, a.transID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
+	return &Store{
+		getDB: getDB,
+	}
+}
@@ -0,0 +1,77 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type Type string
+
+const (
+	// TypeBirthday targets customers whose birthday falls within the next TargetDays days
+	TypeBirthday Type = "birthday"
+	// TypeWinBack targets customers who have not visited in the last TargetDays days
+	TypeWinBack Type = "win_back"
+)
+
+type Campaign struct {
+	ID            int64
+	Name          string
+	Type          Type
+	Channel       string
+	TargetDays    int
+	VoucherCode   string
+	VoucherDetail string
+	ValidUntil    *time.Time
+	CreatedBy     string
+	CreatedAt     time.Time
+	TargetCount   int64
+	ContactCount  int64
+	RedeemCount   int64
+}
+
+// Target is a customer included in a campaign list
+type Target struct {
+	CampaignID            int64
+	VoucherCode           string
+	CustomerID            int64
+	CustomerName          string
+	PhoneNumber           string
+	BirthDate             *time.Time
+	LastTransaction       *time.Time
+	ContactedAt           *time.Time
+	RedeemedAt            *time.Time
+	RedeemedTransactionID *int64
+	Channel               string
+	// HasConsent reports whether the customer currently agrees to be contacted through the campaign channel
+	HasConsent  bool
+	OptOutToken string
+}
+
+var ErrInvalidCampaign = errors.New("Invalid campaign data")
+var ErrNoConsent = errors.New("Customer has not given consent for this channel")
+var ErrTargetNotFound = errors.New("Customer is not a target of this campaign")
+var ErrVoucherNotFound = errors.New("Voucher not found for this customer")
+var ErrVoucherExpired = errors.New("Voucher has expired")
+var ErrVoucherRedeemed = errors.New("Voucher has already been redeemed")
+var ErrVoucherTransaction = errors.New("Transaction does not belong to the voucher customer")
+
+type Service interface {
+	GetAllCampaigns(ctx context.Context) ([]Campaign, error)
+	CreateCampaign(ctx context.Context, c Campaign) (Campaign, error)
+	GetCampaignTargets(ctx context.Context, campaignID int64) ([]Target, error)
+	GetTargetsByCustomerID(ctx context.Context, customerID int64) ([]Target, error)
+	MarkContacted(ctx context.Context, campaignID, customerID int64) error
+	RedeemVoucher(ctx context.Context, voucherCode string, customerID, transactionID int64) error
+}
+
+var defaultService Service
+
+func Init(s Service) {
+	defaultService = s
+}
+
+func GetService() Service {
+	return defaultService
+}
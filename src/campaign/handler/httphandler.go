@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+	"github.com/corneliusdavid97/laundry-go/src/user"
+	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/timer"
+)
+
+type HTTPHandler struct {
+	svc campaign.Service
+	cfg Config
+}
+
+type Config struct {
+	Timeout time.Duration
+}
+
+type Campaign struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	Channel       string  `json:"channel"`
+	TargetDays    int     `json:"target_days"`
+	VoucherCode   string  `json:"voucher_code"`
+	VoucherDetail string  `json:"voucher_detail"`
+	ValidUntil    *string `json:"valid_until"`
+	CreatedBy     string  `json:"created_by"`
+	CreatedAt     string  `json:"created_at"`
+	TargetCount   int64   `json:"target_count"`
+	ContactCount  int64   `json:"contact_count"`
+	RedeemCount   int64   `json:"redeem_count"`
+}
+
+type Target struct {
+	CampaignID            int64   `json:"campaign_id"`
+	VoucherCode           string  `json:"voucher_code"`
+	CustomerID            int64   `json:"customer_id"`
+	CustomerName          string  `json:"customer_name"`
+	PhoneNumber           string  `json:"phone_number"`
+	BirthDate             *string `json:"birth_date"`
+	LastTransaction       *string `json:"last_transaction"`
+	ContactedAt           *string `json:"contacted_at"`
+	RedeemedAt            *string `json:"redeemed_at"`
+	RedeemedTransactionID *int64  `json:"redeemed_transaction_id"`
+	HasConsent            bool    `json:"has_consent"`
+	OptOutToken           string  `json:"opt_out_token"`
+}
+
+type NewCampaignParam struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Channel       string `json:"channel"`
+	TargetDays    int    `json:"target_days"`
+	VoucherCode   string `json:"voucher_code"`
+	VoucherDetail string `json:"voucher_detail"`
+	ValidUntilStr string `json:"valid_until"`
+}
+
+func (h *HTTPHandler) HandleGetAllCampaigns(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	campaigns, err := h.svc.GetAllCampaigns(ctx)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	res := make([]Campaign, 0, len(campaigns))
+	for _, c := range campaigns {
+		res = append(res, parseCampaign(c))
+	}
+
+	httputil.WriteDataResponse(w, res, len(res), t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleNewCampaign(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var request NewCampaignParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	c := campaign.Campaign{
+		Name:          request.Name,
+		Type:          campaign.Type(request.Type),
+		Channel:       request.Channel,
+		TargetDays:    request.TargetDays,
+		VoucherCode:   request.VoucherCode,
+		VoucherDetail: request.VoucherDetail,
+	}
+	if len(request.ValidUntilStr) > 0 {
+		validUntil, err := time.ParseInLocation("2006-01-02", request.ValidUntilStr, time.Local)
+		if err != nil {
+			httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+			return
+		}
+		// valid until the end of the given day
+		validUntil = validUntil.AddDate(0, 0, 1).Add(-time.Second)
+		c.ValidUntil = &validUntil
+	}
+	if u, ok := user.FromContext(r.Context()); ok {
+		c.CreatedBy = u.Username
+	}
+
+	res, err := h.svc.CreateCampaign(ctx, c)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteDataResponse(w, parseCampaign(res), 1, t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleGetCampaignTargets(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	targets, err := h.svc.GetCampaignTargets(ctx, id)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	res := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		res = append(res, parseTarget(target))
+	}
+
+	if r.FormValue("format") == "csv" {
+		httputil.WriteCSVResponse(w, fmt.Sprintf("campaign-%d.csv", id), targetsToCSV(res))
+		return
+	}
+
+	httputil.WriteDataResponse(w, res, len(res), t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleMarkContacted(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	campaignID, err := strconv.ParseInt(r.FormValue("campaign_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+	customerID, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	err = h.svc.MarkContacted(ctx, campaignID, customerID)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteSuccessResponse(w, "Customer marked as contacted", t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleRedeemVoucher(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	customerID, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+	transactionID, err := strconv.ParseInt(r.FormValue("transaction_id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	err = h.svc.RedeemVoucher(ctx, r.FormValue("voucher_code"), customerID, transactionID)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteSuccessResponse(w, "Voucher redeemed", t.GetElapsedTime())
+}
+
+func parseError(err error) httputil.ErrorResponse {
+	status := http.StatusInternalServerError
+	switch err {
+	case campaign.ErrInvalidCampaign, campaign.ErrVoucherTransaction:
+		status = http.StatusBadRequest
+	case campaign.ErrTargetNotFound, campaign.ErrVoucherNotFound:
+		status = http.StatusNotFound
+	case campaign.ErrVoucherExpired, campaign.ErrVoucherRedeemed, campaign.ErrNoConsent:
+		status = http.StatusConflict
+	}
+	return httputil.NewErrorResponse(status, err)
+}
+
+func parseCampaign(c campaign.Campaign) Campaign {
+	return Campaign{
+		ID:            c.ID,
+		Name:          c.Name,
+		Type:          string(c.Type),
+		Channel:       c.Channel,
+		TargetDays:    c.TargetDays,
+		VoucherCode:   c.VoucherCode,
+		VoucherDetail: c.VoucherDetail,
+		ValidUntil:    httputil.FormatTime(c.ValidUntil, "2006-01-02"),
+		CreatedBy:     c.CreatedBy,
+		CreatedAt:     c.CreatedAt.Format("2006-01-02 15:04:05"),
+		TargetCount:   c.TargetCount,
+		ContactCount:  c.ContactCount,
+		RedeemCount:   c.RedeemCount,
+	}
+}
+
+func parseTarget(t campaign.Target) Target {
+	return Target{
+		CampaignID:            t.CampaignID,
+		VoucherCode:           t.VoucherCode,
+		CustomerID:            t.CustomerID,
+		CustomerName:          t.CustomerName,
+		PhoneNumber:           t.PhoneNumber,
+		BirthDate:             httputil.FormatTime(t.BirthDate, "2006-01-02"),
+		LastTransaction:       httputil.FormatTime(t.LastTransaction, "2006-01-02 15:04:05"),
+		ContactedAt:           httputil.FormatTime(t.ContactedAt, "2006-01-02 15:04:05"),
+		RedeemedAt:            httputil.FormatTime(t.RedeemedAt, "2006-01-02 15:04:05"),
+		RedeemedTransactionID: t.RedeemedTransactionID,
+		HasConsent:            t.HasConsent,
+		OptOutToken:           t.OptOutToken,
+	}
+}
+
+// targetsToCSV lists the targets for the messaging tool, customers who withdrew their
+// consent since the list was generated are left out
+func targetsToCSV(targets []Target) [][]string {
+	records := [][]string{
+		{"customer_id", "customer_name", "phone_number", "birth_date", "last_transaction", "voucher_code", "opt_out_token", "contacted_at", "redeemed_at"},
+	}
+	for _, t := range targets {
+		if !t.HasConsent {
+			continue
+		}
+		records = append(records, []string{
+			strconv.FormatInt(t.CustomerID, 10),
+			t.CustomerName,
+			t.PhoneNumber,
+			httputil.DerefString(t.BirthDate),
+			httputil.DerefString(t.LastTransaction),
+			t.VoucherCode,
+			t.OptOutToken,
+			httputil.DerefString(t.ContactedAt),
+			httputil.DerefString(t.RedeemedAt),
+		})
+	}
+	return records
+}
+
+func NewHandler(svc campaign.Service, cfg Config) *HTTPHandler {
+	return &HTTPHandler{
+		svc: svc,
+		cfg: cfg,
+	}
+}
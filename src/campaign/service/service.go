@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+	"github.com/corneliusdavid97/laundry-go/src/customer"
+)
+
+type Service struct {
+	store Store
+}
+
+type Store interface {
+	GetAllCampaigns(ctx context.Context) ([]campaign.Campaign, error)
+	GetCampaignByID(ctx context.Context, ID int64) (campaign.Campaign, error)
+	GetCampaignByVoucherCode(ctx context.Context, voucherCode string) (campaign.Campaign, error)
+	CreateBirthdayCampaign(ctx context.Context, c campaign.Campaign, monthDays []string) (int64, error)
+	CreateWinBackCampaign(ctx context.Context, c campaign.Campaign, lastVisitBefore time.Time) (int64, error)
+	GetCampaignTargets(ctx context.Context, campaignID int64) ([]campaign.Target, error)
+	GetTargetsByCustomerID(ctx context.Context, customerID int64) ([]campaign.Target, error)
+	MarkContacted(ctx context.Context, campaignID, customerID int64) error
+	RedeemVoucher(ctx context.Context, campaignID, customerID, transactionID int64) error
+}
+
+func (s *Service) GetAllCampaigns(ctx context.Context) ([]campaign.Campaign, error) {
+	res, err := s.store.GetAllCampaigns(ctx)
+	if err != nil {
+		return []campaign.Campaign{}, err
+	}
+	return res, nil
+}
+
+// CreateCampaign stores the campaign and generates its target list, only customers who
+// consented to the campaign channel are targeted
+func (s *Service) CreateCampaign(ctx context.Context, c campaign.Campaign) (campaign.Campaign, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	c.VoucherCode = strings.ToUpper(strings.TrimSpace(c.VoucherCode))
+	if len(c.Name) == 0 || len(c.VoucherCode) == 0 || c.TargetDays <= 0 {
+		return campaign.Campaign{}, campaign.ErrInvalidCampaign
+	}
+	if !customer.ValidChannel(customer.Channel(c.Channel)) {
+		return campaign.Campaign{}, campaign.ErrInvalidCampaign
+	}
+
+	var err error
+	now := time.Now()
+	switch c.Type {
+	case campaign.TypeBirthday:
+		monthDays := make([]string, 0, c.TargetDays)
+		for i := 0; i < c.TargetDays; i++ {
+			day := now.AddDate(0, 0, i)
+			monthDays = append(monthDays, day.Format("0102"))
+			// customers born on Feb 29 celebrate on Feb 28 outside leap years
+			if day.Month() == time.February && day.Day() == 28 && !isLeapYear(day.Year()) {
+				monthDays = append(monthDays, "0229")
+			}
+		}
+		c.ID, err = s.store.CreateBirthdayCampaign(ctx, c, monthDays)
+	case campaign.TypeWinBack:
+		c.ID, err = s.store.CreateWinBackCampaign(ctx, c, now.AddDate(0, 0, -c.TargetDays))
+	default:
+		return campaign.Campaign{}, campaign.ErrInvalidCampaign
+	}
+	if err != nil {
+		return campaign.Campaign{}, err
+	}
+	return c, nil
+}
+
+func isLeapYear(year int) bool {
+	return time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Day() == 29
+}
+
+func (s *Service) GetCampaignTargets(ctx context.Context, campaignID int64) ([]campaign.Target, error) {
+	res, err := s.store.GetCampaignTargets(ctx, campaignID)
+	if err != nil {
+		return []campaign.Target{}, err
+	}
+	for i, t := range res {
+		res[i].OptOutToken = customer.GetService().GetOptOutToken(t.CustomerID, customer.Channel(t.Channel))
+	}
+	return res, nil
+}
+
+func (s *Service) GetTargetsByCustomerID(ctx context.Context, customerID int64) ([]campaign.Target, error) {
+	res, err := s.store.GetTargetsByCustomerID(ctx, customerID)
+	if err != nil {
+		return []campaign.Target{}, err
+	}
+	return res, nil
+}
+
+// MarkContacted records that the customer was sent the campaign message, consent is
+// checked again since it may have been withdrawn after the list was generated
+func (s *Service) MarkContacted(ctx context.Context, campaignID, customerID int64) error {
+	c, err := s.store.GetCampaignByID(ctx, campaignID)
+	if err == sql.ErrNoRows {
+		return campaign.ErrTargetNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	consent, err := customer.GetService().HasConsent(ctx, customerID, customer.Channel(c.Channel))
+	if err != nil {
+		return err
+	}
+	if !consent {
+		return campaign.ErrNoConsent
+	}
+
+	err = s.store.MarkContacted(ctx, campaignID, customerID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) RedeemVoucher(ctx context.Context, voucherCode string, customerID, transactionID int64) error {
+	c, err := s.store.GetCampaignByVoucherCode(ctx, strings.ToUpper(strings.TrimSpace(voucherCode)))
+	if err == sql.ErrNoRows {
+		return campaign.ErrVoucherNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if c.ValidUntil != nil && time.Now().After(*c.ValidUntil) {
+		return campaign.ErrVoucherExpired
+	}
+
+	err = s.store.RedeemVoucher(ctx, c.ID, customerID, transactionID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewService(store Store) *Service {
+	return &Service{
+		store: store,
+	}
+}
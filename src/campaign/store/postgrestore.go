@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+)
+
+const queryGetAllCampaigns = `
+	select
+		c.id,
+		c.name,
+		c.campaign_type,
+		c.channel,
+		c.target_days,
+		c.voucher_code,
+		coalesce(c.voucher_detail,''),
+		c.valid_until,
+		c.created_by,
+		c.created_at,
+		count(t.customer_id),
+		count(t.contacted_at),
+		count(t.redeemed_at)
+	from
+		campaign_data c
+		left join campaign_target t on t.campaign_id = c.id
+	group by
+		c.id
+	order by
+		c.created_at desc
+`
+
+const queryGetCampaignByID = `
+	select
+		id,
+		name,
+		campaign_type,
+		channel,
+		target_days,
+		voucher_code,
+		coalesce(voucher_detail,''),
+		valid_until,
+		created_by,
+		created_at
+	from
+		campaign_data
+	where
+		id=$1
+`
+
+const queryGetCampaignByVoucherCode = `
+	select
+		id,
+		name,
+		campaign_type,
+		channel,
+		target_days,
+		voucher_code,
+		coalesce(voucher_detail,''),
+		valid_until,
+		created_by,
+		created_at
+	from
+		campaign_data
+	where
+		voucher_code=$1
+`
+
+const queryInsertCampaign = `
+	insert into campaign_data(
+		name,
+		campaign_type,
+		channel,
+		target_days,
+		voucher_code,
+		voucher_detail,
+		valid_until,
+		created_by
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6,
+		$7,
+		$8
+	)
+	returning id
+`
+
+const queryInsertBirthdayTargets = `
+	insert into campaign_target(
+		campaign_id,
+		customer_id
+	)
+	select
+		$1,
+		id
+	from
+		cust_data
+	where
+		active=true
+		and anonymized_at is null
+		and birth_date is not null
+		and to_char(birth_date, 'MMDD') = any($2)
+		and exists (
+			select 1 from cust_consent cc
+			where cc.customer_id = cust_data.id and cc.channel = $3 and cc.granted = true
+		)
+`
+
+const queryInsertWinBackTargets = `
+	insert into campaign_target(
+		campaign_id,
+		customer_id
+	)
+	select
+		$1,
+		c.id
+	from
+		cust_data c
+		join (
+			select customer_id, max(transaction_time) as last_transaction
+			from transaction_main
+			where status <> 'cancelled'
+			group by customer_id
+		) t on t.customer_id = c.id
+	where
+		c.active=true
+		and c.anonymized_at is null
+		and t.last_transaction < $2
+		and exists (
+			select 1 from cust_consent cc
+			where cc.customer_id = c.id and cc.channel = $3 and cc.granted = true
+		)
+`
+
+const queryGetCampaignTargets = `
+	select
+		t.campaign_id,
+		cd.voucher_code,
+		t.customer_id,
+		c.name,
+		coalesce(c.phone,''),
+		c.birth_date,
+		(select max(transaction_time) from transaction_main where customer_id = t.customer_id and status <> 'cancelled'),
+		t.contacted_at,
+		t.redeemed_at,
+		t.redeemed_transaction_id,
+		cd.channel,
+		coalesce(cc.granted,false)
+	from
+		campaign_target t
+		join campaign_data cd on cd.id = t.campaign_id
+		join cust_data c on c.id = t.customer_id
+		left join cust_consent cc on cc.customer_id = t.customer_id and cc.channel = cd.channel
+	where
+		%s
+	order by
+		c.name
+`
+
+const queryMarkContacted = `
+	update campaign_target set
+		contacted_at=coalesce(contacted_at, now())
+	where
+		campaign_id=$1 and customer_id=$2
+`
+
+// queryRedeemVoucher only accepts a transaction of the targeted customer
+const queryRedeemVoucher = `
+	update campaign_target set
+		redeemed_at=now(),
+		redeemed_transaction_id=$3
+	where
+		campaign_id=$1 and customer_id=$2 and redeemed_at is null
+		and exists (
+			select 1 from transaction_main t
+			where t.id = $3 and t.customer_id = $2
+		)
+`
+
+const queryGetTargetRedeemed = `
+	select
+		redeemed_at is not null
+	from
+		campaign_target
+	where
+		campaign_id=$1 and customer_id=$2
+`
+
+type Store struct {
+	getDB func(dbName, replication string) (*sqlx.DB, error)
+}
+
+func (s *Store) GetAllCampaigns(ctx context.Context) ([]campaign.Campaign, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []campaign.Campaign{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetAllCampaigns)
+	if err != nil {
+		return []campaign.Campaign{}, err
+	}
+	defer rows.Close()
+
+	res := make([]campaign.Campaign, 0)
+	for rows.Next() {
+		var c campaign.Campaign
+		err = rows.Scan(&c.ID, &c.Name, &c.Type, &c.Channel, &c.TargetDays, &c.VoucherCode, &c.VoucherDetail, &c.ValidUntil, &c.CreatedBy, &c.CreatedAt, &c.TargetCount, &c.ContactCount, &c.RedeemCount)
+		if err != nil {
+			return []campaign.Campaign{}, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) GetCampaignByID(ctx context.Context, ID int64) (campaign.Campaign, error) {
+	return s.getCampaign(ctx, queryGetCampaignByID, ID)
+}
+
+func (s *Store) GetCampaignByVoucherCode(ctx context.Context, voucherCode string) (campaign.Campaign, error) {
+	return s.getCampaign(ctx, queryGetCampaignByVoucherCode, voucherCode)
+}
+
+func (s *Store) getCampaign(ctx context.Context, query string, param interface{}) (campaign.Campaign, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return campaign.Campaign{}, err
+	}
+
+	row := db.QueryRowContext(ctx, query, param)
+	var c campaign.Campaign
+	err = row.Scan(&c.ID, &c.Name, &c.Type, &c.Channel, &c.TargetDays, &c.VoucherCode, &c.VoucherDetail, &c.ValidUntil, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return campaign.Campaign{}, err
+	}
+	return c, nil
+}
+
+func (s *Store) CreateBirthdayCampaign(ctx context.Context, c campaign.Campaign, monthDays []string) (int64, error) {
+	return s.createCampaign(ctx, c, queryInsertBirthdayTargets, pq.Array(monthDays))
+}
+
+func (s *Store) CreateWinBackCampaign(ctx context.Context, c campaign.Campaign, lastVisitBefore time.Time) (int64, error) {
+	return s.createCampaign(ctx, c, queryInsertWinBackTargets, lastVisitBefore)
+}
+
+// createCampaign inserts the campaign and its targets in one transaction, targetQuery
+// receives the new campaign id, targetParam and the campaign channel
+func (s *Store) createCampaign(ctx context.Context, c campaign.Campaign, targetQuery string, targetParam interface{}) (int64, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, queryInsertCampaign, c.Name, c.Type, c.Channel, c.TargetDays, c.VoucherCode, c.VoucherDetail, c.ValidUntil, c.CreatedBy).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, targetQuery, id, targetParam, c.Channel)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (s *Store) GetCampaignTargets(ctx context.Context, campaignID int64) ([]campaign.Target, error) {
+	return s.getTargets(ctx, "t.campaign_id=$1", campaignID)
+}
+
+func (s *Store) GetTargetsByCustomerID(ctx context.Context, customerID int64) ([]campaign.Target, error) {
+	return s.getTargets(ctx, "t.customer_id=$1", customerID)
+}
+
+func (s *Store) getTargets(ctx context.Context, where string, param interface{}) ([]campaign.Target, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []campaign.Target{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(queryGetCampaignTargets, where), param)
+	if err != nil {
+		return []campaign.Target{}, err
+	}
+	defer rows.Close()
+
+	res := make([]campaign.Target, 0)
+	for rows.Next() {
+		var t campaign.Target
+		err = rows.Scan(&t.CampaignID, &t.VoucherCode, &t.CustomerID, &t.CustomerName, &t.PhoneNumber, &t.BirthDate, &t.LastTransaction, &t.ContactedAt, &t.RedeemedAt, &t.RedeemedTransactionID, &t.Channel, &t.HasConsent)
+		if err != nil {
+			return []campaign.Target{}, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) MarkContacted(ctx context.Context, campaignID, customerID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, queryMarkContacted, campaignID, customerID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return campaign.ErrTargetNotFound
+	}
+	return nil
+}
+
+func (s *Store) RedeemVoucher(ctx context.Context, campaignID, customerID, transactionID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, queryRedeemVoucher, campaignID, customerID, transactionID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// nothing updated, find out whether the customer was never targeted, already redeemed or
+	// the transaction is not theirs
+	var redeemed bool
+	err = db.QueryRowContext(ctx, queryGetTargetRedeemed, campaignID, customerID).Scan(&redeemed)
+	if err == sql.ErrNoRows {
+		return campaign.ErrVoucherNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return campaign.ErrVoucherTransaction
+	}
+	return campaign.ErrVoucherRedeemed
+}
+
+func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
+	return &Store{
+		getDB: getDB,
+	}
+}
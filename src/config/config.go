@@ -0,0 +1,159 @@
+// Package config provide application wide configuration loaded from config file
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+type Config struct {
+	Report     ReportConfig     `yaml:"report"`
+	Credit     CreditConfig     `yaml:"credit"`
+	Consent    ConsentConfig    `yaml:"consent"`
+	Turnaround TurnaroundConfig `yaml:"turnaround"`
+	Outlet     OutletConfig     `yaml:"outlet"`
+	Tax        TaxConfig        `yaml:"tax"`
+	Rounding   RoundingConfig   `yaml:"rounding"`
+	Files      FilesConfig      `yaml:"files"`
+	Product    ProductConfig    `yaml:"product"`
+}
+
+type ProductConfig struct {
+	// CacheSeconds is how long product lists are cached in memory, zero disables the cache
+	CacheSeconds int `yaml:"cache_seconds"`
+	// NotifyChanges keeps the caches of several app instances in sync with postgres LISTEN/NOTIFY
+	NotifyChanges bool `yaml:"notify_changes"`
+}
+
+// FilesConfig selects where uploaded files such as product images are stored, Driver is
+// currently only local
+type FilesConfig struct {
+	Driver string `yaml:"driver"`
+	// LocalDir is the directory of the local driver, relative paths are relative to the app directory
+	LocalDir string `yaml:"local_dir"`
+}
+
+// RoundingConfig rounds transaction grand totals to a multiple of Unit rupiah, Mode is one of
+// nearest, up or down
+type RoundingConfig struct {
+	Unit int64  `yaml:"unit"`
+	Mode string `yaml:"mode"`
+}
+
+type OutletConfig struct {
+	// Codes are the valid outlet codes, the first one is used for transactions without an outlet
+	Codes []string `yaml:"codes"`
+}
+
+type TaxConfig struct {
+	Rules []TaxRule `yaml:"rules"`
+}
+
+// TaxRule is a tax or charge computed as a percentage of the discounted line amounts
+type TaxRule struct {
+	Name string `yaml:"name"`
+	// Kind is either tax or charge
+	Kind string  `yaml:"kind"`
+	Rate float64 `yaml:"rate"`
+	// Inclusive rules are already part of the product prices and are not added on top of them
+	Inclusive bool `yaml:"inclusive"`
+	// Outlets limits the rule to some outlets, empty applies it to every outlet
+	Outlets []string `yaml:"outlets"`
+	// Products limits the rule to some products, empty applies it to every product
+	Products []string `yaml:"products"`
+}
+
+// TurnaroundConfig is the number of days an order takes to be ready for each service speed,
+// it is used to estimate due dates
+type TurnaroundConfig struct {
+	StandardDays        int `yaml:"standard_days"`
+	ExpressTodayDays    int `yaml:"express_today_days"`
+	ExpressTomorrowDays int `yaml:"express_tomorrow_days"`
+}
+
+type ConsentConfig struct {
+	// TokenSecret signs the opt-out link tokens sent to customers, the CONSENT_TOKEN_SECRET
+	// environment variable overrides it
+	TokenSecret string `yaml:"token_secret"`
+}
+
+type CreditConfig struct {
+	// DefaultLimit is the unpaid balance allowed for customers without the pay later flag,
+	// pay later and corporate customers use their own credit limit instead
+	DefaultLimit money.Money `yaml:"default_limit"`
+}
+
+type ReportConfig struct {
+	RFM RFMConfig `yaml:"rfm"`
+}
+
+// RFMConfig holds the thresholds used to score customers, every threshold met adds one
+// point on top of the base score of 1
+type RFMConfig struct {
+	// RecencyDays are descending day counts, a customer scores a point for each one their last visit is within
+	RecencyDays []int `yaml:"recency_days"`
+	// Frequency are ascending transaction counts
+	Frequency []int `yaml:"frequency"`
+	// Monetary are ascending total spending amounts
+	Monetary []money.Money `yaml:"monetary"`
+	// Segments are matched in order, the first segment whose score ranges match is assigned
+	Segments []RFMSegment `yaml:"segments"`
+}
+
+// RFMSegment matches customers by score range, a zero bound is not checked
+type RFMSegment struct {
+	Name         string `yaml:"name"`
+	MinRecency   int    `yaml:"min_recency"`
+	MaxRecency   int    `yaml:"max_recency"`
+	MinFrequency int    `yaml:"min_frequency"`
+	MaxFrequency int    `yaml:"max_frequency"`
+	MinMonetary  int    `yaml:"min_monetary"`
+	MaxMonetary  int    `yaml:"max_monetary"`
+}
+
+// envConsentTokenSecret keeps the opt-out token secret out of the tracked config file
+const envConsentTokenSecret = "CONSENT_TOKEN_SECRET"
+
+var globalLock = sync.RWMutex{}
+var globalConfig Config
+
+// InitConfig reads the global config file
+func InitConfig(basepath string) error {
+	filepath := basepath + "/etc/config/global.yaml"
+	file, err := os.Open(filepath)
+	if err != nil {
+		return fmt.Errorf("%s, path: %s", err.Error(), filepath)
+	}
+	defer file.Close()
+
+	var cfg Config
+	decoder := yaml.NewDecoder(file)
+	if err := decoder.Decode(&cfg); err != nil {
+		return errors.New("failed to parse config")
+	}
+
+	if secret := os.Getenv(envConsentTokenSecret); secret != "" {
+		cfg.Consent.TokenSecret = secret
+	}
+	if cfg.Consent.TokenSecret == "" {
+		return fmt.Errorf("consent token secret is empty, set %s", envConsentTokenSecret)
+	}
+
+	globalLock.Lock()
+	globalConfig = cfg
+	globalLock.Unlock()
+
+	return nil
+}
+
+func Get() Config {
+	globalLock.RLock()
+	defer globalLock.RUnlock()
+	return globalConfig
+}
@@ -3,22 +3,93 @@ package customer
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+	"github.com/corneliusdavid97/laundry-go/src/transaction"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
 )
 
 type Customer struct {
-	ID          int64
-	Name        string
-	PhoneNumber string
-	Address     string
-	Active      bool
+	ID           int64
+	Name         string
+	PhoneNumber  string
+	Address      string
+	BirthDate    *time.Time
+	IsCorporate  bool
+	PayLater     bool
+	CreditLimit  money.Money
+	Blacklisted  bool
+	Active       bool
+	AnonymizedAt *time.Time
+}
+
+// CreditSettings controls how much unpaid balance a customer may carry
+type CreditSettings struct {
+	PayLater    bool
+	CreditLimit money.Money
+	Blacklisted bool
+}
+
+type Channel string
+
+const (
+	ChannelWhatsApp Channel = "whatsapp"
+	ChannelSMS      Channel = "sms"
+	ChannelEmail    Channel = "email"
+)
+
+// ValidChannel reports whether c is a supported marketing channel
+func ValidChannel(c Channel) bool {
+	switch c {
+	case ChannelWhatsApp, ChannelSMS, ChannelEmail:
+		return true
+	}
+	return false
+}
+
+// ConsentMethodOptOutLink is recorded when a customer opts out through their opt-out link
+const ConsentMethodOptOutLink = "opt_out_link"
+
+// Consent is a marketing consent change of a customer for a single channel,
+// the latest change per channel is the current consent
+type Consent struct {
+	CustomerID int64
+	Channel    Channel
+	Granted    bool
+	Method     string
+	RecordedBy string
+	RecordedAt time.Time
+}
+
+// DataExport holds everything stored about a single customer
+type DataExport struct {
+	ExportedAt   time.Time
+	Customer     Customer
+	Transactions []transaction.Transaction
+	Campaigns    []campaign.Target
+	Consents     []Consent
 }
 
 var ErrInvalidCustomer = errors.New("Invalid customer data")
+var ErrCustomerAnonymized = errors.New("Customer data has already been anonymized")
+var ErrInvalidConsent = errors.New("Invalid consent data")
+var ErrInvalidOptOutToken = errors.New("Invalid opt-out token")
 
 type Service interface {
 	GetAllActiveCustomer(ctx context.Context) ([]Customer, error)
 	GetCustomerByID(ctx context.Context, id int64) (Customer, error)
 	InsertNewCustomer(ctx context.Context, cust Customer) error
+	ExportCustomerData(ctx context.Context, id int64) (DataExport, error)
+	AnonymizeCustomer(ctx context.Context, id int64) error
+	SetCorporate(ctx context.Context, id int64, isCorporate bool) error
+	SetCreditSettings(ctx context.Context, id int64, settings CreditSettings) error
+	RecordConsent(ctx context.Context, consent Consent) error
+	GetConsents(ctx context.Context, id int64) ([]Consent, error)
+	GetConsentHistory(ctx context.Context, id int64) ([]Consent, error)
+	HasConsent(ctx context.Context, id int64, channel Channel) (bool, error)
+	GetOptOutToken(id int64, channel Channel) string
+	OptOut(ctx context.Context, token string) error
 }
 
 var defaultService Service
@@ -3,11 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/corneliusdavid97/laundry-go/src/customer"
+	"github.com/corneliusdavid97/laundry-go/src/transaction"
 	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
 	"github.com/corneliusdavid97/laundry-go/tools/timer"
 )
 
@@ -21,11 +25,47 @@ type Config struct {
 }
 
 type Customer struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	PhoneNumber string `json:"phone_number"`
-	Address     string `json:"address"`
-	Active      bool   `json:"active"`
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	PhoneNumber string      `json:"phone_number"`
+	Address     string      `json:"address"`
+	BirthDate   *string     `json:"birth_date"`
+	IsCorporate bool        `json:"is_corporate"`
+	PayLater    bool        `json:"pay_later"`
+	CreditLimit money.Money `json:"credit_limit"`
+	Blacklisted bool        `json:"blacklisted"`
+	Active      bool        `json:"active"`
+}
+
+type CustomerDataExport struct {
+	ExportedAt   string                    `json:"exported_at"`
+	Customer     Customer                  `json:"customer"`
+	AnonymizedAt *string                   `json:"anonymized_at"`
+	Transactions []transaction.Transaction `json:"transactions"`
+	Campaigns    []CampaignContact         `json:"campaigns"`
+	Consents     []Consent                 `json:"consents"`
+}
+
+type Consent struct {
+	Channel    string `json:"channel"`
+	Granted    bool   `json:"granted"`
+	Method     string `json:"method"`
+	RecordedBy string `json:"recorded_by"`
+	RecordedAt string `json:"recorded_at"`
+}
+
+type CustomerConsents struct {
+	CustomerID int64     `json:"customer_id"`
+	Current    []Consent `json:"current"`
+	History    []Consent `json:"history"`
+}
+
+type CampaignContact struct {
+	CampaignID            int64   `json:"campaign_id"`
+	VoucherCode           string  `json:"voucher_code"`
+	ContactedAt           *string `json:"contacted_at"`
+	RedeemedAt            *string `json:"redeemed_at"`
+	RedeemedTransactionID *int64  `json:"redeemed_transaction_id"`
 }
 
 func (h *HTTPHandler) HandleGetAllActiveCustomer(w http.ResponseWriter, r *http.Request) {
@@ -99,10 +139,29 @@ func (h *HTTPHandler) HandleInsertNewCustomer(w http.ResponseWriter, r *http.Req
 	var respErrs []httputil.ErrorResponse
 
 	r.ParseForm()
+	var birthDate *time.Time
+	if sBirthDate := r.FormValue("birth_date"); len(sBirthDate) > 0 {
+		b, err := time.ParseInLocation("2006-01-02", sBirthDate, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+		birthDate = &b
+	}
+
+	isCorporate, _ := strconv.ParseBool(r.FormValue("is_corporate"))
+
 	err := h.svc.InsertNewCustomer(ctx, customer.Customer{
 		Name:        r.FormValue("name"),
 		PhoneNumber: r.FormValue("phone_number"),
 		Address:     r.FormValue("address"),
+		BirthDate:   birthDate,
+		IsCorporate: isCorporate,
 	})
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
@@ -148,14 +207,661 @@ func (h *HTTPHandler) HandleInsertNewCustomer(w http.ResponseWriter, r *http.Req
 	httputil.WriteResponse(w, respJson)
 }
 
+func (h *HTTPHandler) HandleExportCustomerData(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	export, err := h.svc.ExportCustomerData(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: parseCustomerDataExport(export),
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="customer-%d.json"`, id))
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleAnonymizeCustomer(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.AnonymizeCustomer(ctx, id)
+	if err == customer.ErrCustomerAnonymized {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusConflict,
+			Title:      http.StatusText(http.StatusConflict),
+			Detail:     err.Error(),
+		})
+	} else if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Customer data anonymized",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleSetCorporate(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	isCorporate, err := strconv.ParseBool(r.FormValue("is_corporate"))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.SetCorporate(ctx, id, isCorporate)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Customer account type updated",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleSetCreditSettings(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	var settings customer.CreditSettings
+	settings.PayLater, err = strconv.ParseBool(r.FormValue("pay_later"))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	settings.CreditLimit, err = money.Parse(r.FormValue("credit_limit"))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	settings.Blacklisted, err = strconv.ParseBool(r.FormValue("blacklisted"))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.SetCreditSettings(ctx, id, settings)
+	if err == customer.ErrInvalidCustomer {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	} else if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Customer credit settings updated",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func parseCustomerDataExport(export customer.DataExport) CustomerDataExport {
+	res := CustomerDataExport{
+		ExportedAt:   export.ExportedAt.Format("2006-01-02 15:04:05"),
+		Customer:     parseCustomer(export.Customer),
+		Transactions: make([]transaction.Transaction, 0, len(export.Transactions)),
+		Campaigns:    make([]CampaignContact, 0, len(export.Campaigns)),
+		Consents:     parseConsents(export.Consents),
+	}
+	if export.Customer.AnonymizedAt != nil {
+		anonymizedAt := export.Customer.AnonymizedAt.Format("2006-01-02 15:04:05")
+		res.AnonymizedAt = &anonymizedAt
+	}
+	for _, trans := range export.Transactions {
+		res.Transactions = append(res.Transactions, parseTransaction(trans))
+	}
+	for _, target := range export.Campaigns {
+		contact := CampaignContact{
+			CampaignID:            target.CampaignID,
+			VoucherCode:           target.VoucherCode,
+			RedeemedTransactionID: target.RedeemedTransactionID,
+		}
+		if target.ContactedAt != nil {
+			contactedAt := target.ContactedAt.Format("2006-01-02 15:04:05")
+			contact.ContactedAt = &contactedAt
+		}
+		if target.RedeemedAt != nil {
+			redeemedAt := target.RedeemedAt.Format("2006-01-02 15:04:05")
+			contact.RedeemedAt = &redeemedAt
+		}
+		res.Campaigns = append(res.Campaigns, contact)
+	}
+	return res
+}
+
+func parseTransaction(trans transaction.Transaction) transaction.Transaction {
+	if trans.TransactionTime != nil {
+		transactionTimeStr := trans.TransactionTime.Format("2006-01-02 15:04:05")
+		trans.TransactionTimeStr = &transactionTimeStr
+	}
+	if trans.DueDate != nil {
+		dueDateStr := trans.DueDate.Format("2006-01-02")
+		trans.DueDateStr = &dueDateStr
+	}
+	if trans.DateReady != nil {
+		dateReadyStr := trans.DateReady.Format("2006-01-02 15:04:05")
+		trans.DateReadyStr = &dateReadyStr
+	}
+	if trans.DateTaken != nil {
+		dateTakenStr := trans.DateTaken.Format("2006-01-02 15:04:05")
+		trans.DateTakenStr = &dateTakenStr
+	}
+	for i := range trans.Payments {
+		trans.Payments[i].PaidAtStr = trans.Payments[i].PaidAt.Format("2006-01-02 15:04:05")
+	}
+	return trans
+}
+
+func (h *HTTPHandler) HandleRecordConsent(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	granted, err := strconv.ParseBool(r.FormValue("granted"))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.RecordConsent(ctx, customer.Consent{
+		CustomerID: id,
+		Channel:    customer.Channel(r.FormValue("channel")),
+		Granted:    granted,
+		Method:     r.FormValue("method"),
+		RecordedBy: r.FormValue("recorded_by"),
+	})
+	if err == customer.ErrInvalidConsent {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	} else if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Consent recorded",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleGetConsents(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	current, err := h.svc.GetConsents(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	history, err := h.svc.GetConsentHistory(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: CustomerConsents{
+			CustomerID: id,
+			Current:    parseConsents(current),
+			History:    parseConsents(history),
+		},
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+// HandleOptOut serves the opt-out link sent to customers, so it accepts plain GET requests
+// without a content type
+func (h *HTTPHandler) HandleOptOut(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusMethodNotAllowed,
+				Title:      http.StatusText(http.StatusMethodNotAllowed),
+				Detail:     fmt.Sprintf("Method %s not supported, only GET and POST allowed", r.Method),
+			},
+		})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	err := h.svc.OptOut(ctx, r.FormValue("token"))
+	if err == customer.ErrInvalidOptOutToken {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	} else if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "You have been unsubscribed from our promotional messages",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func parseConsents(consents []customer.Consent) []Consent {
+	res := make([]Consent, 0, len(consents))
+	for _, c := range consents {
+		res = append(res, Consent{
+			Channel:    string(c.Channel),
+			Granted:    c.Granted,
+			Method:     c.Method,
+			RecordedBy: c.RecordedBy,
+			RecordedAt: c.RecordedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return res
+}
+
 func parseCustomer(cust customer.Customer) Customer {
-	return Customer{
+	res := Customer{
 		ID:          cust.ID,
 		Name:        cust.Name,
 		PhoneNumber: cust.PhoneNumber,
 		Address:     cust.Address,
+		IsCorporate: cust.IsCorporate,
+		PayLater:    cust.PayLater,
+		CreditLimit: cust.CreditLimit,
+		Blacklisted: cust.Blacklisted,
 		Active:      cust.Active,
 	}
+	if cust.BirthDate != nil {
+		birthDate := cust.BirthDate.Format("2006-01-02")
+		res.BirthDate = &birthDate
+	}
+	return res
 }
 
 func NewHandler(svc customer.Service, cfg Config) *HTTPHandler {
@@ -2,18 +2,35 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/corneliusdavid97/laundry-go/src/campaign"
+	"github.com/corneliusdavid97/laundry-go/src/config"
 	"github.com/corneliusdavid97/laundry-go/src/customer"
+	"github.com/corneliusdavid97/laundry-go/src/transaction"
 )
 
 type Service struct {
-	store Store
+	store      Store
+	consentCfg config.ConsentConfig
 }
 
 type Store interface {
 	GetAllCustomer(ctx context.Context, active bool) ([]customer.Customer, error)
 	GetCustomerByID(ctx context.Context, ID int64) (customer.Customer, error)
 	InsertNewCustomer(ctx context.Context, cust customer.Customer) error
+	AnonymizeCustomer(ctx context.Context, ID int64, placeholderName string) error
+	SetCorporate(ctx context.Context, ID int64, isCorporate bool) error
+	SetCreditSettings(ctx context.Context, ID int64, settings customer.CreditSettings) error
+	RecordConsent(ctx context.Context, consent customer.Consent) error
+	GetConsents(ctx context.Context, ID int64) ([]customer.Consent, error)
+	GetConsentHistory(ctx context.Context, ID int64) ([]customer.Consent, error)
 }
 
 func (s *Service) GetAllActiveCustomer(ctx context.Context) ([]customer.Customer, error) {
@@ -36,6 +53,9 @@ func (s *Service) InsertNewCustomer(ctx context.Context, cust customer.Customer)
 	if len(cust.Name) == 0 {
 		return customer.ErrInvalidCustomer
 	}
+	if cust.BirthDate != nil && cust.BirthDate.After(time.Now()) {
+		return customer.ErrInvalidCustomer
+	}
 	err := s.store.InsertNewCustomer(ctx, cust)
 	if err != nil {
 		return err
@@ -43,8 +63,207 @@ func (s *Service) InsertNewCustomer(ctx context.Context, cust customer.Customer)
 	return nil
 }
 
-func NewService(store Store) *Service {
+func (s *Service) ExportCustomerData(ctx context.Context, ID int64) (customer.DataExport, error) {
+	cust, err := s.store.GetCustomerByID(ctx, ID)
+	if err != nil {
+		return customer.DataExport{}, err
+	}
+
+	trans, err := transaction.GetService().GetTransactionsByCustomerID(ctx, ID)
+	if err != nil {
+		return customer.DataExport{}, err
+	}
+
+	campaigns, err := campaign.GetService().GetTargetsByCustomerID(ctx, ID)
+	if err != nil {
+		return customer.DataExport{}, err
+	}
+
+	consents, err := s.store.GetConsentHistory(ctx, ID)
+	if err != nil {
+		return customer.DataExport{}, err
+	}
+
+	return customer.DataExport{
+		ExportedAt:   time.Now(),
+		Customer:     cust,
+		Transactions: trans,
+		Campaigns:    campaigns,
+		Consents:     consents,
+	}, nil
+}
+
+// AnonymizeCustomer scrubs the personal data of a customer, the row itself is kept as
+// a placeholder identity so the transaction history stays linked for bookkeeping
+func (s *Service) AnonymizeCustomer(ctx context.Context, ID int64) error {
+	cust, err := s.store.GetCustomerByID(ctx, ID)
+	if err != nil {
+		return err
+	}
+	if cust.AnonymizedAt != nil {
+		return customer.ErrCustomerAnonymized
+	}
+
+	err = s.store.AnonymizeCustomer(ctx, ID, fmt.Sprintf("Anonymized customer #%d", ID))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetCorporate marks a customer as a corporate account, their transactions may stay unpaid
+// until they are consolidated into a monthly invoice
+func (s *Service) SetCorporate(ctx context.Context, ID int64, isCorporate bool) error {
+	cust, err := s.store.GetCustomerByID(ctx, ID)
+	if err != nil {
+		return err
+	}
+	if cust.AnonymizedAt != nil {
+		return customer.ErrCustomerAnonymized
+	}
+
+	err = s.store.SetCorporate(ctx, ID, isCorporate)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) SetCreditSettings(ctx context.Context, ID int64, settings customer.CreditSettings) error {
+	if settings.CreditLimit < 0 {
+		return customer.ErrInvalidCustomer
+	}
+	cust, err := s.store.GetCustomerByID(ctx, ID)
+	if err != nil {
+		return err
+	}
+	if cust.AnonymizedAt != nil {
+		return customer.ErrCustomerAnonymized
+	}
+
+	err = s.store.SetCreditSettings(ctx, ID, settings)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) RecordConsent(ctx context.Context, consent customer.Consent) error {
+	consent.Method = strings.TrimSpace(consent.Method)
+	if !customer.ValidChannel(consent.Channel) || len(consent.Method) == 0 {
+		return customer.ErrInvalidConsent
+	}
+	cust, err := s.store.GetCustomerByID(ctx, consent.CustomerID)
+	if err != nil {
+		return err
+	}
+	if cust.AnonymizedAt != nil {
+		return customer.ErrCustomerAnonymized
+	}
+
+	err = s.store.RecordConsent(ctx, consent)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetConsents returns the current consent of the customer for every channel ever recorded
+func (s *Service) GetConsents(ctx context.Context, ID int64) ([]customer.Consent, error) {
+	res, err := s.store.GetConsents(ctx, ID)
+	if err != nil {
+		return []customer.Consent{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) GetConsentHistory(ctx context.Context, ID int64) ([]customer.Consent, error) {
+	res, err := s.store.GetConsentHistory(ctx, ID)
+	if err != nil {
+		return []customer.Consent{}, err
+	}
+	return res, nil
+}
+
+// HasConsent reports whether the customer currently agrees to be contacted through channel,
+// a customer who never gave consent is treated as not consenting
+func (s *Service) HasConsent(ctx context.Context, ID int64, channel customer.Channel) (bool, error) {
+	consents, err := s.store.GetConsents(ctx, ID)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range consents {
+		if c.Channel == channel {
+			return c.Granted, nil
+		}
+	}
+	return false, nil
+}
+
+// GetOptOutToken returns a signed token identifying the customer and channel, to be
+// embedded in the opt-out link of promotional messages
+func (s *Service) GetOptOutToken(ID int64, channel customer.Channel) string {
+	payload := fmt.Sprintf("%d:%s", ID, channel)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.signOptOutPayload(payload)
+}
+
+// OptOut withdraws the consent identified by an opt-out token
+func (s *Service) OptOut(ctx context.Context, token string) error {
+	ID, channel, err := s.parseOptOutToken(token)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.RecordConsent(ctx, customer.Consent{
+		CustomerID: ID,
+		Channel:    channel,
+		Granted:    false,
+		Method:     customer.ConsentMethodOptOutLink,
+		RecordedBy: "customer",
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) parseOptOutToken(token string) (int64, customer.Channel, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || len(s.consentCfg.TokenSecret) == 0 {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(s.signOptOutPayload(string(payload)))) {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+
+	fields := strings.SplitN(string(payload), ":", 2)
+	if len(fields) != 2 {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+	ID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+	channel := customer.Channel(fields[1])
+	if !customer.ValidChannel(channel) {
+		return 0, "", customer.ErrInvalidOptOutToken
+	}
+	return ID, channel, nil
+}
+
+func (s *Service) signOptOutPayload(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.consentCfg.TokenSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func NewService(store Store, consentCfg config.ConsentConfig) *Service {
 	return &Service{
-		store: store,
+		store:      store,
+		consentCfg: consentCfg,
 	}
 }
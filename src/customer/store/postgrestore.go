@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/corneliusdavid97/laundry-go/src/customer"
 	"github.com/jmoiron/sqlx"
@@ -14,6 +15,11 @@ const queryGetAllCustomer = `
 		name,
 		coalesce(phone,''),
 		coalesce(address,''),
+		birth_date,
+		is_corporate,
+		pay_later,
+		credit_limit,
+		blacklisted,
 		active
 	from
 		cust_data
@@ -26,7 +32,13 @@ const queryGetCustomerByID = `
 		name,
 		coalesce(phone,''),
 		coalesce(address,''),
-		active
+		birth_date,
+		is_corporate,
+		pay_later,
+		credit_limit,
+		blacklisted,
+		active,
+		anonymized_at
 	from
 		cust_data
 	where
@@ -37,15 +49,119 @@ const queryInsertNewCustomer = `
 	insert into cust_data (
 		name, 
 		phone, 
-		address
+		address,
+		birth_date,
+		is_corporate
 	)values(
 		$1,
 		$2,
-		$3
+		$3,
+		$4,
+		$5
 	)
 	
 `
 
+const queryAnonymizeCustomer = `
+	update cust_data set
+		name=$2,
+		phone=null,
+		address=null,
+		birth_date=null,
+		active=false,
+		anonymized_at=now()
+	where
+		id=$1 and anonymized_at is null
+`
+
+const querySetCorporate = `
+	update cust_data set
+		is_corporate=$2
+	where
+		id=$1
+`
+
+const querySetCreditSettings = `
+	update cust_data set
+		pay_later=$2,
+		credit_limit=$3,
+		blacklisted=$4
+	where
+		id=$1
+`
+
+const queryInsertConsentHistory = `
+	insert into cust_consent_history(
+		customer_id,
+		channel,
+		granted,
+		method,
+		recorded_by
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5
+	)
+	returning recorded_at
+`
+
+const queryUpsertConsent = `
+	insert into cust_consent(
+		customer_id,
+		channel,
+		granted,
+		method,
+		recorded_by,
+		recorded_at
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6
+	)
+	on conflict (customer_id, channel) do update set
+		granted=excluded.granted,
+		method=excluded.method,
+		recorded_by=excluded.recorded_by,
+		recorded_at=excluded.recorded_at
+`
+
+const queryGetConsents = `
+	select
+		customer_id,
+		channel,
+		granted,
+		method,
+		recorded_by,
+		recorded_at
+	from
+		cust_consent
+	where
+		customer_id=$1
+	order by
+		channel
+`
+
+const queryGetConsentHistory = `
+	select
+		customer_id,
+		channel,
+		granted,
+		method,
+		recorded_by,
+		recorded_at
+	from
+		cust_consent_history
+	where
+		customer_id=$1
+	order by
+		recorded_at
+`
+
 type Store struct {
 	getDB func(dbName, replication string) (*sqlx.DB, error)
 }
@@ -63,7 +179,7 @@ func (s *Store) GetAllCustomer(ctx context.Context, active bool) ([]customer.Cus
 	var res []customer.Customer
 	for rows.Next() {
 		var cust customer.Customer
-		err = rows.Scan(&cust.ID, &cust.Name, &cust.PhoneNumber, &cust.Address, &cust.Active)
+		err = rows.Scan(&cust.ID, &cust.Name, &cust.PhoneNumber, &cust.Address, &cust.BirthDate, &cust.IsCorporate, &cust.PayLater, &cust.CreditLimit, &cust.Blacklisted, &cust.Active)
 		if err != nil {
 			log.Printf("Failed to scan customer, err:%v, cust:%v", err, cust)
 		} else {
@@ -80,7 +196,7 @@ func (s *Store) GetCustomerByID(ctx context.Context, ID int64) (customer.Custome
 	}
 	row := db.QueryRowContext(ctx, queryGetCustomerByID, ID)
 	var cust customer.Customer
-	err = row.Scan(&cust.ID, &cust.Name, &cust.PhoneNumber, &cust.Address, &cust.Active)
+	err = row.Scan(&cust.ID, &cust.Name, &cust.PhoneNumber, &cust.Address, &cust.BirthDate, &cust.IsCorporate, &cust.PayLater, &cust.CreditLimit, &cust.Blacklisted, &cust.Active, &cust.AnonymizedAt)
 	if err != nil {
 		return customer.Customer{}, err
 	}
@@ -93,13 +209,119 @@ func (s *Store) InsertNewCustomer(ctx context.Context, cust customer.Customer) e
 		return err
 	}
 
-	_, err = db.ExecContext(ctx, queryInsertNewCustomer, cust.Name, cust.PhoneNumber, cust.Address)
+	_, err = db.ExecContext(ctx, queryInsertNewCustomer, cust.Name, cust.PhoneNumber, cust.Address, cust.BirthDate, cust.IsCorporate)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (s *Store) AnonymizeCustomer(ctx context.Context, ID int64, placeholderName string) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, queryAnonymizeCustomer, ID, placeholderName)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return customer.ErrCustomerAnonymized
+	}
+	return nil
+}
+
+func (s *Store) SetCorporate(ctx context.Context, ID int64, isCorporate bool) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, querySetCorporate, ID, isCorporate)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) SetCreditSettings(ctx context.Context, ID int64, settings customer.CreditSettings) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, querySetCreditSettings, ID, settings.PayLater, settings.CreditLimit, settings.Blacklisted)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecordConsent appends the change to the consent history and updates the current consent
+func (s *Store) RecordConsent(ctx context.Context, consent customer.Consent) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	var recordedAt time.Time
+	err = tx.QueryRowContext(ctx, queryInsertConsentHistory, consent.CustomerID, consent.Channel, consent.Granted, consent.Method, consent.RecordedBy).Scan(&recordedAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryUpsertConsent, consent.CustomerID, consent.Channel, consent.Granted, consent.Method, consent.RecordedBy, recordedAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) GetConsents(ctx context.Context, ID int64) ([]customer.Consent, error) {
+	return s.getConsents(ctx, queryGetConsents, ID)
+}
+
+func (s *Store) GetConsentHistory(ctx context.Context, ID int64) ([]customer.Consent, error) {
+	return s.getConsents(ctx, queryGetConsentHistory, ID)
+}
+
+func (s *Store) getConsents(ctx context.Context, query string, ID int64) ([]customer.Consent, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []customer.Consent{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, ID)
+	if err != nil {
+		return []customer.Consent{}, err
+	}
+	defer rows.Close()
+
+	res := make([]customer.Consent, 0)
+	for rows.Next() {
+		var c customer.Consent
+		err = rows.Scan(&c.CustomerID, &c.Channel, &c.Granted, &c.Method, &c.RecordedBy, &c.RecordedAt)
+		if err != nil {
+			return []customer.Consent{}, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
 func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
 	return &Store{
 		getDB: getDB,
@@ -2,13 +2,20 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/corneliusdavid97/laundry-go/src/product"
 	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/querybuilder"
 	"github.com/corneliusdavid97/laundry-go/tools/timer"
 )
 
@@ -21,6 +28,43 @@ type Config struct {
 	Timeout time.Duration
 }
 
+type Price struct {
+	ID                   int64       `json:"id,omitempty"`
+	ProductID            int64       `json:"product_id"`
+	PriceStandard        money.Money `json:"price_standard"`
+	PriceExpressToday    money.Money `json:"price_express_today"`
+	PriceExpressTomorrow money.Money `json:"price_express_tomorrow"`
+	EffectiveFrom        *string     `json:"effective_from"`
+	EffectiveUntil       *string     `json:"effective_until"`
+}
+
+type SchedulePriceParam struct {
+	ProductID            int64       `json:"product_id"`
+	PriceStandard        money.Money `json:"price_standard"`
+	PriceExpressToday    money.Money `json:"price_express_today"`
+	PriceExpressTomorrow money.Money `json:"price_express_tomorrow"`
+	EffectiveFromStr     string      `json:"effective_from"`
+}
+
+type AdjustPriceParam struct {
+	ProductIDs       []int64 `json:"product_ids"`
+	Category         string  `json:"category"`
+	Type             string  `json:"type"`
+	Value            float64 `json:"value"`
+	RoundingUnit     int64   `json:"rounding_unit"`
+	RoundingMode     string  `json:"rounding_mode"`
+	EffectiveFromStr string  `json:"effective_from"`
+	// Preview returns the adjusted prices without saving them
+	Preview bool `json:"preview"`
+}
+
+type AdjustedPrice struct {
+	ProductID   int64  `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Old         Price  `json:"old"`
+	New         Price  `json:"new"`
+}
+
 func (h *HTTPHandler) HandleGetAllActiveProduct(w http.ResponseWriter, r *http.Request) {
 	t := timer.NewTimer()
 
@@ -36,16 +80,37 @@ func (h *HTTPHandler) HandleGetAllActiveProduct(w http.ResponseWriter, r *http.R
 	var respErrs []httputil.ErrorResponse
 
 	// construct queries
-	var filter product.Filter
-	sActive := r.URL.Query().Get("active")
-	if len(sActive) > 0 {
-		b, _ := strconv.ParseBool(sActive)
-		filter.Active = &b
+	params := querybuilder.NewParams(r.URL.Query())
+	filter := product.Filter{
+		Active:     params.Bool("active"),
+		IsSatuan:   params.Bool("is_satuan"),
+		Name:       params.String("name"),
+		CustomerID: params.Int64("customer_id"),
+		Limit:      params.Int("limit"),
+		Offset:     params.Int("offset"),
 	}
-	sSatuan := r.URL.Query().Get("is_satuan")
-	if len(sSatuan) > 0 {
-		b, _ := strconv.ParseBool(sSatuan)
-		filter.IsSatuan = &b
+	filter.Sort, filter.SortDesc = params.Sort("sort", product.SortID, product.SortName, product.SortPrice, product.SortCategory, product.SortOrder)
+	if category := params.OneOf("category", string(product.CategoryKiloan), string(product.CategorySatuan),
+		string(product.CategoryDryClean), string(product.CategoryShoes), string(product.CategoryCarpet)); category != "" {
+		c := product.Category(category)
+		filter.Category = &c
+	}
+	if unit := params.OneOf("unit", string(product.UnitKg), string(product.UnitPiece), string(product.UnitPair),
+		string(product.UnitM2), string(product.UnitSet)); unit != "" {
+		u := product.Unit(unit)
+		filter.Unit = &u
+	}
+	filter.MinPrice = params.Money("min_price")
+	filter.MaxPrice = params.Money("max_price")
+	if params.Err() != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     params.Err().Error(),
+			},
+		})
+		return
 	}
 
 	products, err := h.svc.GetAllActiveProducts(ctx, filter)
@@ -60,6 +125,15 @@ func (h *HTTPHandler) HandleGetAllActiveProduct(w http.ResponseWriter, r *http.R
 		httputil.WriteErrorResponse(w, respErrs)
 		return
 	}
+	for i := range products {
+		products[i] = withImageURLs(products[i])
+	}
+
+	// tablets poll the catalog, the ETag only covers the products so it ignores the process time
+	productsJson, err := json.Marshal(products)
+	if err == nil && httputil.WriteNotModified(w, r, productsJson) {
+		return
+	}
 
 	resp := httputil.Response{
 		Data: products,
@@ -84,6 +158,1125 @@ func (h *HTTPHandler) HandleGetAllActiveProduct(w http.ResponseWriter, r *http.R
 	httputil.WriteResponse(w, respJson)
 }
 
+func (h *HTTPHandler) HandleGetProductByID(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	p, err := h.svc.GetProductByID(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeProductResponse(w, p, t)
+}
+
+func (h *HTTPHandler) HandleAddNewProduct(w http.ResponseWriter, r *http.Request) {
+	h.handleWriteProduct(w, r, h.svc.AddNewProduct, false)
+}
+
+func (h *HTTPHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	h.handleWriteProduct(w, r, h.svc.UpdateProduct, true)
+}
+
+// handleWriteProduct decodes a product from the request body and passes it to write, a partial
+// body is decoded onto the current product so the fields it omits keep their current value
+func (h *HTTPHandler) handleWriteProduct(w http.ResponseWriter, r *http.Request, write func(ctx context.Context, p product.Product) (product.Product, error), partial bool) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request product.Product
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	if partial {
+		var target struct {
+			ID int64 `json:"id"`
+		}
+		err = json.Unmarshal(data, &target)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+
+		request, err = h.svc.GetProductByID(ctx, target.ID)
+		if err != nil {
+			respErrs = append(respErrs, parseError(err))
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	p, err := write(ctx, request)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeProductResponse(w, p, t)
+}
+
+func (h *HTTPHandler) HandleDeactivateProduct(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.DeactivateProduct(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Product deactivated",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+// maxUploadSize leaves room for the multipart encoding around a 5 MB image
+const maxUploadSize = 6 << 20
+
+func (h *HTTPHandler) HandleUploadProductImage(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeMultipart)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		respErrs = append(respErrs, parseError(product.ErrInvalidImage))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		respErrs = append(respErrs, parseError(product.ErrInvalidImage))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		respErrs = append(respErrs, parseError(product.ErrInvalidImage))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	p, err := h.svc.SetProductImage(ctx, id, data)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeProductResponse(w, p, t)
+}
+
+// HandleGetProductImage serves the product image, or its thumbnail with size=thumbnail. Image URLs
+// carry the upload version in v so responses to them can be cached for good, other requests are
+// revalidated with the ETag.
+func (h *HTTPHandler) HandleGetProductImage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	// images are requested by img tags, which send no content type
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusMethodNotAllowed,
+				Title:      http.StatusText(http.StatusMethodNotAllowed),
+				Detail:     fmt.Sprintf("Method %s not supported, only %s allowed", r.Method, http.MethodGet),
+			},
+		})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			},
+		})
+		return
+	}
+
+	img, err := h.svc.GetProductImage(ctx, id, r.FormValue("size") == "thumbnail")
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+	defer img.Content.Close()
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", `"`+path.Base(img.Path)+`"`)
+	if v := r.FormValue("v"); v != "" && v == imageVersion(img.Path) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, no-cache")
+	}
+	http.ServeContent(w, r, "", img.Content.ModTime(), img.Content)
+}
+
+// withImageURLs fills the image URLs of a product with an uploaded image
+func withImageURLs(p product.Product) product.Product {
+	if p.ImagePath == "" {
+		return p
+	}
+	v := imageVersion(p.ImagePath)
+	p.ImageURL = fmt.Sprintf("/product/image?id=%d&v=%s", p.ID, v)
+	p.ThumbnailURL = fmt.Sprintf("/product/image?id=%d&size=thumbnail&v=%s", p.ID, v)
+	return p
+}
+
+// imageVersion returns the upload stamp shared by an image and its thumbnail, e.g. 1697712345
+// for product/12/1697712345_thumb.png
+func imageVersion(name string) string {
+	base := path.Base(name)
+	if i := strings.IndexAny(base, "._"); i >= 0 {
+		return base[:i]
+	}
+	return base
+}
+
+func (h *HTTPHandler) HandleGetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	prices, err := h.svc.GetPriceHistory(ctx, productID)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res := make([]Price, 0, len(prices))
+	for _, p := range prices {
+		res = append(res, parsePrice(p))
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleGetPriceAsOf(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	at := time.Now()
+	if sAt := r.FormValue("at"); len(sAt) > 0 {
+		at, err = time.ParseInLocation("2006-01-02 15:04:05", sAt, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+	}
+
+	price, err := h.svc.GetPriceAsOf(ctx, productID, at)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: parsePrice(price),
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleSchedulePriceChange(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request SchedulePriceParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	price := product.Price{
+		ProductID:            request.ProductID,
+		PriceStandard:        request.PriceStandard,
+		PriceExpressToday:    request.PriceExpressToday,
+		PriceExpressTomorrow: request.PriceExpressTomorrow,
+	}
+	if len(request.EffectiveFromStr) > 0 {
+		price.EffectiveFrom, err = time.ParseInLocation("2006-01-02 15:04:05", request.EffectiveFromStr, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+	}
+
+	err = h.svc.SchedulePriceChange(ctx, price)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Price change scheduled",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleAdjustPrices(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request AdjustPriceParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	adj := product.PriceAdjustment{
+		ProductIDs:   request.ProductIDs,
+		Category:     product.Category(request.Category),
+		Type:         product.AdjustmentType(request.Type),
+		Value:        request.Value,
+		RoundingUnit: request.RoundingUnit,
+		RoundingMode: money.RoundingMode(request.RoundingMode),
+	}
+	if len(request.EffectiveFromStr) > 0 {
+		adj.EffectiveFrom, err = time.ParseInLocation("2006-01-02 15:04:05", request.EffectiveFromStr, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+	}
+
+	adjust := h.svc.AdjustPrices
+	if request.Preview {
+		adjust = h.svc.PreviewPriceAdjustment
+	}
+	adjusted, err := adjust(ctx, adj)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res := make([]AdjustedPrice, 0, len(adjusted))
+	for _, a := range adjusted {
+		res = append(res, AdjustedPrice{
+			ProductID:   a.ProductID,
+			ProductName: a.ProductName,
+			Old:         parsePrice(a.Old),
+			New:         parsePrice(a.New),
+		})
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func parsePrice(p product.Price) Price {
+	res := Price{
+		ID:                   p.ID,
+		ProductID:            p.ProductID,
+		PriceStandard:        p.PriceStandard,
+		PriceExpressToday:    p.PriceExpressToday,
+		PriceExpressTomorrow: p.PriceExpressTomorrow,
+	}
+	if !p.EffectiveFrom.IsZero() {
+		effectiveFrom := p.EffectiveFrom.Format("2006-01-02 15:04:05")
+		res.EffectiveFrom = &effectiveFrom
+	}
+	if p.EffectiveUntil != nil {
+		effectiveUntil := p.EffectiveUntil.Format("2006-01-02 15:04:05")
+		res.EffectiveUntil = &effectiveUntil
+	}
+	return res
+}
+
+func writeProductResponse(w http.ResponseWriter, p product.Product, t *timer.Timer) {
+	resp := httputil.Response{
+		Data: withImageURLs(p),
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func parseError(err error) httputil.ErrorResponse {
+	status := http.StatusInternalServerError
+	switch err {
+	case product.ErrInvalidProduct, product.ErrInvalidPrice, product.ErrInvalidEffectiveDate, product.ErrInvalidAddon,
+		product.ErrInvalidUnit, product.ErrInvalidCategory, product.ErrInvalidQuantityRule, product.ErrInvalidAdjustment,
+		product.ErrInvalidDisplay, product.ErrInvalidImage:
+		status = http.StatusBadRequest
+	case product.ErrImageNotFound:
+		status = http.StatusNotFound
+	case product.ErrDuplicateProduct:
+		status = http.StatusConflict
+	case sql.ErrNoRows:
+		status = http.StatusNotFound
+	}
+	return httputil.ErrorResponse{
+		HttpStatus: httputil.HttpStatus(status),
+		Title:      http.StatusText(status),
+		Detail:     err.Error(),
+	}
+}
+
+func (h *HTTPHandler) HandleGetCustomerPrices(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	customerID, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	prices, err := h.svc.GetCustomerPrices(ctx, customerID)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: prices,
+		Meta: &httputil.Meta{
+			DataCount:   len(prices),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleSetCustomerPrice(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request product.CustomerPrice
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.SetCustomerPrice(ctx, request)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Customer price saved",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleDeleteCustomerPrice(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	customerID, err := strconv.ParseInt(r.FormValue("customer_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.DeleteCustomerPrice(ctx, customerID, productID)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Customer price removed",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleGetAddons(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	active := true
+	filter := product.AddonFilter{
+		Active: &active,
+	}
+	if sProductID := r.FormValue("product_id"); len(sProductID) > 0 {
+		productID, err := strconv.ParseInt(sProductID, 10, 64)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+		filter.ProductID = &productID
+	}
+
+	addons, err := h.svc.GetAddons(ctx, filter)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: addons,
+		Meta: &httputil.Meta{
+			DataCount:   len(addons),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleAddAddon(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request product.Addon
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	addon, err := h.svc.AddAddon(ctx, request)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: addon,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleDeactivateAddon(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = h.svc.DeactivateAddon(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  "Add-on deactivated",
+	}
+
+	resp := httputil.Response{
+		Data: respData,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
 func NewHandler(svc product.Service, cfg Config) *HTTPHandler {
 	return &HTTPHandler{
 		svc: svc,
@@ -1,26 +1,244 @@
 package product
 
-import "context"
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/filestore"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+type Unit string
+
+const (
+	UnitKg    Unit = "kg"
+	UnitPiece Unit = "piece"
+	UnitPair  Unit = "pair"
+	UnitM2    Unit = "m2"
+	UnitSet   Unit = "set"
+)
+
+func (u Unit) Valid() bool {
+	switch u {
+	case UnitKg, UnitPiece, UnitPair, UnitM2, UnitSet:
+		return true
+	}
+	return false
+}
+
+type Category string
+
+const (
+	CategoryKiloan   Category = "kiloan"
+	CategorySatuan   Category = "satuan"
+	CategoryDryClean Category = "dry_clean"
+	CategoryShoes    Category = "shoes"
+	CategoryCarpet   Category = "carpet"
+)
+
+func (c Category) Valid() bool {
+	switch c {
+	case CategoryKiloan, CategorySatuan, CategoryDryClean, CategoryShoes, CategoryCarpet:
+		return true
+	}
+	return false
+}
 
 type Product struct {
-	ID                   int64   `json:"id"`
-	Name                 string  `json:"name"`
-	PriceStandard        float64 `json:"price_standard"`
-	PriceExpressToday    float64 `json:"price_express_today"`
-	PriceExpressTomorrow float64 `json:"price_express_tomorrow"`
-	IsSatuan             bool    `json:"is_satuan"`
-	Active               bool    `json:"active"`
+	ID                   int64       `json:"id"`
+	Name                 string      `json:"name"`
+	PriceStandard        money.Money `json:"price_standard"`
+	PriceExpressToday    money.Money `json:"price_express_today"`
+	PriceExpressTomorrow money.Money `json:"price_express_tomorrow"`
+	// IsSatuan is kept for older clients, it is true for every unit other than kg
+	IsSatuan bool     `json:"is_satuan"`
+	Unit     Unit     `json:"unit"`
+	Category Category `json:"category"`
+	// MinQuantity is the minimum chargeable quantity of a transaction line, zero means no minimum
+	MinQuantity float64 `json:"min_quantity"`
+	// QuantityStep is the increment line quantities must be a multiple of, e.g. 0.1 kg or
+	// 1 piece, zero allows any quantity
+	QuantityStep float64 `json:"quantity_step"`
+	// SortOrder positions the product on the POS grid, lower values come first
+	SortOrder int `json:"sort_order"`
+	// Color is the tile colour in #rrggbb form and Icon the name of the tile icon, both optional
+	Color  string `json:"color"`
+	Icon   string `json:"icon"`
+	Active bool   `json:"active"`
+	// ImagePath and ThumbnailPath are the file store names of the product image, empty
+	// when no image was uploaded. The URLs are filled by the HTTP handler.
+	ImagePath     string `json:"-"`
+	ThumbnailPath string `json:"-"`
+	ImageURL      string `json:"image_url,omitempty"`
+	ThumbnailURL  string `json:"thumbnail_url,omitempty"`
+}
+
+// Image is an opened product image file, the caller must close Content
+type Image struct {
+	Path        string
+	ContentType string
+	Content     filestore.File
+}
+
+// quantityEpsilon absorbs float error of quantities such as 2.7 kg in step checks
+const quantityEpsilon = 1e-6
+
+// ValidQuantity reports whether quantity satisfies the product minimum and step rules
+func (p Product) ValidQuantity(quantity float64) bool {
+	if quantity <= 0 || quantity < p.MinQuantity-quantityEpsilon {
+		return false
+	}
+	if p.QuantityStep <= 0 {
+		return true
+	}
+	steps := quantity / p.QuantityStep
+	return math.Abs(steps-math.Round(steps)) < quantityEpsilon*math.Max(1, steps)
+}
+
+// CustomerPrice is a negotiated price of a product for a single customer,
+// it overrides the product prices for that customer
+type CustomerPrice struct {
+	CustomerID           int64       `json:"customer_id"`
+	ProductID            int64       `json:"product_id"`
+	ProductName          string      `json:"product_name"`
+	PriceStandard        money.Money `json:"price_standard"`
+	PriceExpressToday    money.Money `json:"price_express_today"`
+	PriceExpressTomorrow money.Money `json:"price_express_tomorrow"`
 }
 
+// Price is the set of product prices effective from EffectiveFrom until EffectiveUntil,
+// a nil EffectiveUntil means the price is effective until further change
+type Price struct {
+	ID                   int64       `json:"id"`
+	ProductID            int64       `json:"product_id"`
+	PriceStandard        money.Money `json:"price_standard"`
+	PriceExpressToday    money.Money `json:"price_express_today"`
+	PriceExpressTomorrow money.Money `json:"price_express_tomorrow"`
+	EffectiveFrom        time.Time   `json:"-"`
+	EffectiveUntil       *time.Time  `json:"-"`
+}
+
+type AdjustmentType string
+
+const (
+	// AdjustmentPercentage changes every price by Value percent
+	AdjustmentPercentage AdjustmentType = "percentage"
+	// AdjustmentFixed adds Value rupiah to every price
+	AdjustmentFixed AdjustmentType = "fixed"
+)
+
+// PriceAdjustment changes the prices of the products of a category or of explicit product IDs,
+// a negative Value lowers the prices. New prices are rounded to RoundingUnit, customer price
+// overrides are not adjusted.
+type PriceAdjustment struct {
+	ProductIDs    []int64
+	Category      Category
+	Type          AdjustmentType
+	Value         float64
+	RoundingUnit  int64
+	RoundingMode  money.RoundingMode
+	EffectiveFrom time.Time
+}
+
+// AdjustedPrice is the price of a product before and after an adjustment
+type AdjustedPrice struct {
+	ProductID   int64
+	ProductName string
+	Old         Price
+	New         Price
+}
+
+type AddonPriceType string
+
+const (
+	// AddonPriceFixed charges the add-on price once per transaction line
+	AddonPriceFixed AddonPriceType = "fixed"
+	// AddonPricePerQuantity charges the add-on price for every kg or piece of the line
+	AddonPricePerQuantity AddonPriceType = "per_quantity"
+)
+
+// Addon is an extra service that can be selected on a transaction line of its product,
+// e.g. stain treatment or plastic wrapping
+type Addon struct {
+	ID        int64          `json:"id"`
+	ProductID int64          `json:"product_id"`
+	Name      string         `json:"name"`
+	PriceType AddonPriceType `json:"price_type"`
+	Price     money.Money    `json:"price"`
+	Active    bool           `json:"active"`
+}
+
+type AddonFilter struct {
+	ProductID *int64
+	Active    *bool
+}
+
+const (
+	SortID       = "id"
+	SortName     = "name"
+	SortPrice    = "price"
+	SortCategory = "category"
+	SortOrder    = "sort_order"
+)
+
+// Filter selects products, a nil Active only returns active products
 type Filter struct {
 	IsSatuan *bool
 	Active   *bool
+	Category *Category
+	Unit     *Unit
+	// Name matches products whose name contains it, case insensitive
+	Name string
+	// MinPrice and MaxPrice bound the effective standard price
+	MinPrice *money.Money
+	MaxPrice *money.Money
+	// CustomerID applies the customer price overrides to the returned products
+	CustomerID *int64
+	// Sort is one of the Sort constants, products are sorted by their sort order when empty
+	Sort     string
+	SortDesc bool
+	// Limit of 0 returns every matching product
+	Limit  int
+	Offset int
 }
 
+var ErrInvalidPrice = errors.New("Invalid product price, prices must not be negative and express prices must not be lower than the standard price")
+var ErrInvalidProduct = errors.New("Invalid product data")
+var ErrInvalidEffectiveDate = errors.New("Price changes can only be scheduled from now on")
+var ErrInvalidAddon = errors.New("Invalid add-on data")
+var ErrInvalidUnit = errors.New("Invalid product unit, must be one of kg, piece, pair, m2 or set")
+var ErrInvalidCategory = errors.New("Invalid product category, must be one of kiloan, satuan, dry_clean, shoes or carpet")
+var ErrInvalidQuantityRule = errors.New("Invalid quantity rule, minimum quantity and step must not be negative")
+var ErrInvalidAdjustment = errors.New("Invalid price adjustment, select a category or product IDs and a percentage or fixed value")
+var ErrInvalidDisplay = errors.New("Invalid product display, colour must be in #rrggbb form and sort order must not be negative")
+var ErrInvalidImage = errors.New("Invalid image, only JPEG, PNG and GIF images up to 5 MB are supported")
+var ErrImageNotFound = errors.New("Product has no image")
+var ErrDuplicateProduct = errors.New("An active product with the same name already exists")
+
 type Service interface {
 	GetAllActiveProducts(ctx context.Context, filter Filter) ([]Product, error)
-	// GetProductByID(ctx context.Context, ID int64) (Product, error)
-	// AddNewProduct(ctx context.Context, product Product) error
+	GetProductByID(ctx context.Context, ID int64) (Product, error)
+	AddNewProduct(ctx context.Context, product Product) (Product, error)
+	UpdateProduct(ctx context.Context, product Product) (Product, error)
+	DeactivateProduct(ctx context.Context, ID int64) error
+	// SetProductImage stores the image and its thumbnail and replaces the previous image of the product
+	SetProductImage(ctx context.Context, ID int64, data []byte) (Product, error)
+	GetProductImage(ctx context.Context, ID int64, thumbnail bool) (Image, error)
+	GetPriceHistory(ctx context.Context, productID int64) ([]Price, error)
+	SchedulePriceChange(ctx context.Context, price Price) error
+	GetPriceAsOf(ctx context.Context, productID int64, at time.Time) (Price, error)
+	// PreviewPriceAdjustment computes the prices an adjustment would set without saving them
+	PreviewPriceAdjustment(ctx context.Context, adj PriceAdjustment) ([]AdjustedPrice, error)
+	// AdjustPrices schedules the adjusted prices of every selected product in one transaction
+	AdjustPrices(ctx context.Context, adj PriceAdjustment) ([]AdjustedPrice, error)
+	GetAddons(ctx context.Context, filter AddonFilter) ([]Addon, error)
+	AddAddon(ctx context.Context, addon Addon) (Addon, error)
+	DeactivateAddon(ctx context.Context, ID int64) error
+	GetCustomerPrices(ctx context.Context, customerID int64) ([]CustomerPrice, error)
+	SetCustomerPrice(ctx context.Context, price CustomerPrice) error
+	DeleteCustomerPrice(ctx context.Context, customerID, productID int64) error
 }
 
 var defaultService Service
@@ -1,29 +1,703 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/corneliusdavid97/laundry-go/src/product"
+	"github.com/corneliusdavid97/laundry-go/tools/filestore"
+	"github.com/corneliusdavid97/laundry-go/tools/imageutil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
 )
 
+const maxProductNameLength = 100
+const maxIconLength = 50
+
+const maxImageSize = 5 << 20
+
+// maxImagePixels guards against small files decoding into huge images
+const maxImagePixels = 25000000
+const thumbnailSize = 256
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// CatalogChannel is the postgres notification channel other instances listen on to drop
+// their cached catalog
+const CatalogChannel = "product_catalog"
+
+// maxCachedFilters bounds the cache, searches and pages can create many distinct filters
+const maxCachedFilters = 1000
+
 type Service struct {
 	store Store
+	files filestore.Store
+	cfg   Config
+	cache catalogCache
+}
+
+type Config struct {
+	// CacheTTL is how long product lists are cached, zero disables the cache
+	CacheTTL time.Duration
+	// NotifyChanges sends a notification on CatalogChannel after every catalog change
+	NotifyChanges bool
+}
+
+// catalogCache holds product lists by filter key, generation is bumped on every invalidation
+// so a list read before a change is never stored after it
+type catalogCache struct {
+	mu         sync.RWMutex
+	generation int64
+	entries    map[string]catalogEntry
+}
+
+type catalogEntry struct {
+	products  []product.Product
+	expiresAt time.Time
 }
 
 type Store interface {
 	GetAllProduct(ctx context.Context, filter product.Filter) ([]product.Product, error)
+	GetProductByID(ctx context.Context, ID int64) (product.Product, error)
+	GetActiveProductByName(ctx context.Context, name string) (product.Product, error)
+	InsertProduct(ctx context.Context, p product.Product, effectiveFrom time.Time) (int64, error)
+	UpdateProduct(ctx context.Context, p product.Product, price *product.Price) error
+	DeactivateProduct(ctx context.Context, ID int64) error
+	UpdateProductImage(ctx context.Context, ID int64, imagePath, thumbnailPath string) error
+	GetPriceHistory(ctx context.Context, productID int64) ([]product.Price, error)
+	GetPriceAsOf(ctx context.Context, productID int64, at time.Time) (product.Price, error)
+	SchedulePriceChange(ctx context.Context, price product.Price) error
+	SchedulePriceChanges(ctx context.Context, prices []product.Price) error
+	GetAddons(ctx context.Context, filter product.AddonFilter) ([]product.Addon, error)
+	InsertAddon(ctx context.Context, addon product.Addon) (int64, error)
+	DeactivateAddon(ctx context.Context, ID int64) error
+	GetCustomerPrices(ctx context.Context, customerID int64) ([]product.CustomerPrice, error)
+	UpsertCustomerPrice(ctx context.Context, price product.CustomerPrice) error
+	DeleteCustomerPrice(ctx context.Context, customerID, productID int64) error
+	GetNextPriceChange(ctx context.Context) (*time.Time, error)
+	Notify(ctx context.Context, channel string) error
 }
 
 func (s *Service) GetAllActiveProducts(ctx context.Context, filter product.Filter) ([]product.Product, error) {
+	if s.cfg.CacheTTL <= 0 {
+		res, err := s.store.GetAllProduct(ctx, filter)
+		if err != nil {
+			return []product.Product{}, err
+		}
+		return res, nil
+	}
+
+	key := cacheKey(filter)
+	now := time.Now()
+	s.cache.mu.RLock()
+	entry, ok := s.cache.entries[key]
+	generation := s.cache.generation
+	s.cache.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return copyProducts(entry.products), nil
+	}
+
 	res, err := s.store.GetAllProduct(ctx, filter)
 	if err != nil {
 		return []product.Product{}, err
 	}
+
+	// prices change without a write when a scheduled price becomes effective
+	expiresAt := now.Add(s.cfg.CacheTTL)
+	next, err := s.store.GetNextPriceChange(ctx)
+	if err != nil {
+		return res, nil
+	}
+	if next != nil && next.Before(expiresAt) {
+		expiresAt = *next
+	}
+
+	s.cache.mu.Lock()
+	if s.cache.generation == generation {
+		if s.cache.entries == nil || len(s.cache.entries) >= maxCachedFilters {
+			s.cache.entries = make(map[string]catalogEntry)
+		}
+		s.cache.entries[key] = catalogEntry{
+			products:  res,
+			expiresAt: expiresAt,
+		}
+	}
+	s.cache.mu.Unlock()
+	return copyProducts(res), nil
+}
+
+// InvalidateCache drops every cached product list, it is called on catalog changes of this
+// instance and on notifications from other instances
+func (s *Service) InvalidateCache() {
+	s.cache.mu.Lock()
+	s.cache.generation++
+	s.cache.entries = nil
+	s.cache.mu.Unlock()
+}
+
+// catalogChanged invalidates the cache after a write and tells the other instances to do the same
+func (s *Service) catalogChanged(ctx context.Context) {
+	s.InvalidateCache()
+	if !s.cfg.NotifyChanges {
+		return
+	}
+	err := s.store.Notify(ctx, CatalogChannel)
+	if err != nil {
+		log.Printf("[Product][Service] failed to notify catalog change, err:%v\n", err)
+	}
+}
+
+// cacheKey is a canonical form of the filter, filters selecting the same products share a key
+func cacheKey(f product.Filter) string {
+	parts := []string{"-", "-", "-", "-", "-", "-", "-"}
+	if f.Active != nil {
+		parts[0] = strconv.FormatBool(*f.Active)
+	}
+	if f.IsSatuan != nil {
+		parts[1] = strconv.FormatBool(*f.IsSatuan)
+	}
+	if f.Category != nil {
+		parts[2] = string(*f.Category)
+	}
+	if f.Unit != nil {
+		parts[3] = string(*f.Unit)
+	}
+	if f.MinPrice != nil {
+		parts[4] = f.MinPrice.String()
+	}
+	if f.MaxPrice != nil {
+		parts[5] = f.MaxPrice.String()
+	}
+	if f.CustomerID != nil {
+		parts[6] = strconv.FormatInt(*f.CustomerID, 10)
+	}
+	parts = append(parts, strconv.Quote(strings.ToLower(f.Name)), f.Sort, strconv.FormatBool(f.SortDesc),
+		strconv.Itoa(f.Limit), strconv.Itoa(f.Offset))
+	return strings.Join(parts, "|")
+}
+
+// copyProducts keeps callers from modifying the cached list
+func copyProducts(products []product.Product) []product.Product {
+	res := make([]product.Product, len(products))
+	copy(res, products)
+	return res
+}
+
+func (s *Service) GetAddons(ctx context.Context, filter product.AddonFilter) ([]product.Addon, error) {
+	res, err := s.store.GetAddons(ctx, filter)
+	if err != nil {
+		return []product.Addon{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) AddAddon(ctx context.Context, addon product.Addon) (product.Addon, error) {
+	addon.Name = strings.TrimSpace(addon.Name)
+	addon.Active = true
+	if len(addon.Name) == 0 || len(addon.Name) > maxProductNameLength || addon.Price < 0 {
+		return product.Addon{}, product.ErrInvalidAddon
+	}
+	if addon.PriceType != product.AddonPriceFixed && addon.PriceType != product.AddonPricePerQuantity {
+		return product.Addon{}, product.ErrInvalidAddon
+	}
+
+	_, err := s.store.GetProductByID(ctx, addon.ProductID)
+	if err != nil {
+		return product.Addon{}, err
+	}
+
+	addon.ID, err = s.store.InsertAddon(ctx, addon)
+	if err != nil {
+		return product.Addon{}, err
+	}
+	return addon, nil
+}
+
+func (s *Service) DeactivateAddon(ctx context.Context, ID int64) error {
+	err := s.store.DeactivateAddon(ctx, ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) GetCustomerPrices(ctx context.Context, customerID int64) ([]product.CustomerPrice, error) {
+	res, err := s.store.GetCustomerPrices(ctx, customerID)
+	if err != nil {
+		return []product.CustomerPrice{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) SetCustomerPrice(ctx context.Context, price product.CustomerPrice) error {
+	if !validPrices(price.PriceStandard, price.PriceExpressToday, price.PriceExpressTomorrow) {
+		return product.ErrInvalidPrice
+	}
+	err := s.store.UpsertCustomerPrice(ctx, price)
+	if err != nil {
+		return err
+	}
+	s.catalogChanged(ctx)
+	return nil
+}
+
+func (s *Service) DeleteCustomerPrice(ctx context.Context, customerID, productID int64) error {
+	err := s.store.DeleteCustomerPrice(ctx, customerID, productID)
+	if err != nil {
+		return err
+	}
+	s.catalogChanged(ctx)
+	return nil
+}
+
+func (s *Service) GetProductByID(ctx context.Context, ID int64) (product.Product, error) {
+	res, err := s.store.GetProductByID(ctx, ID)
+	if err != nil {
+		return product.Product{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) AddNewProduct(ctx context.Context, p product.Product) (product.Product, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Active = true
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+	p.Icon = strings.TrimSpace(p.Icon)
+	p = normalizeUnit(p)
+	err := s.validateProduct(ctx, p)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	p.ID, err = s.store.InsertProduct(ctx, p, time.Now())
+	if err != nil {
+		return product.Product{}, err
+	}
+	s.catalogChanged(ctx)
+	return p, nil
+}
+
+func (s *Service) UpdateProduct(ctx context.Context, p product.Product) (product.Product, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	current, err := s.store.GetProductByID(ctx, p.ID)
+	if err != nil {
+		return product.Product{}, err
+	}
+	p.Active = current.Active
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+	p.Icon = strings.TrimSpace(p.Icon)
+	p.ImagePath = current.ImagePath
+	p.ThumbnailPath = current.ThumbnailPath
+	if p.Unit == "" {
+		p.Unit = current.Unit
+	}
+	if p.Category == "" {
+		p.Category = current.Category
+	}
+	p = normalizeUnit(p)
+
+	err = s.validateProduct(ctx, p)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	// a price edit takes effect immediately and is kept in the price history
+	var price *product.Price
+	if p.PriceStandard != current.PriceStandard || p.PriceExpressToday != current.PriceExpressToday || p.PriceExpressTomorrow != current.PriceExpressTomorrow {
+		price = &product.Price{
+			ProductID:            p.ID,
+			PriceStandard:        p.PriceStandard,
+			PriceExpressToday:    p.PriceExpressToday,
+			PriceExpressTomorrow: p.PriceExpressTomorrow,
+			EffectiveFrom:        time.Now(),
+		}
+	}
+
+	err = s.store.UpdateProduct(ctx, p, price)
+	if err != nil {
+		return product.Product{}, err
+	}
+	s.catalogChanged(ctx)
+	return p, nil
+}
+
+func (s *Service) GetPriceHistory(ctx context.Context, productID int64) ([]product.Price, error) {
+	res, err := s.store.GetPriceHistory(ctx, productID)
+	if err != nil {
+		return []product.Price{}, err
+	}
 	return res, nil
 }
 
-func NewService(store Store) *Service {
+// SchedulePriceChange adds a price change taking effect at price.EffectiveFrom,
+// a zero EffectiveFrom takes effect immediately
+func (s *Service) SchedulePriceChange(ctx context.Context, price product.Price) error {
+	now := time.Now()
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = now
+	}
+	if price.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return product.ErrInvalidEffectiveDate
+	}
+	if !validPrices(price.PriceStandard, price.PriceExpressToday, price.PriceExpressTomorrow) {
+		return product.ErrInvalidPrice
+	}
+	_, err := s.store.GetProductByID(ctx, price.ProductID)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.SchedulePriceChange(ctx, price)
+	if err != nil {
+		return err
+	}
+	s.catalogChanged(ctx)
+	return nil
+}
+
+// GetPriceAsOf returns the prices of the product effective at the given time, products
+// without a price history entry at that time fall back to their current prices
+func (s *Service) GetPriceAsOf(ctx context.Context, productID int64, at time.Time) (product.Price, error) {
+	res, err := s.store.GetPriceAsOf(ctx, productID, at)
+	if err == nil {
+		return res, nil
+	}
+	if err != sql.ErrNoRows {
+		return product.Price{}, err
+	}
+
+	p, err := s.store.GetProductByID(ctx, productID)
+	if err != nil {
+		return product.Price{}, err
+	}
+	return product.Price{
+		ProductID:            p.ID,
+		PriceStandard:        p.PriceStandard,
+		PriceExpressToday:    p.PriceExpressToday,
+		PriceExpressTomorrow: p.PriceExpressTomorrow,
+	}, nil
+}
+
+func (s *Service) PreviewPriceAdjustment(ctx context.Context, adj product.PriceAdjustment) ([]product.AdjustedPrice, error) {
+	return s.adjustedPrices(ctx, adj)
+}
+
+func (s *Service) AdjustPrices(ctx context.Context, adj product.PriceAdjustment) ([]product.AdjustedPrice, error) {
+	res, err := s.adjustedPrices(ctx, adj)
+	if err != nil {
+		return []product.AdjustedPrice{}, err
+	}
+
+	prices := make([]product.Price, 0, len(res))
+	for _, a := range res {
+		prices = append(prices, a.New)
+	}
+	err = s.store.SchedulePriceChanges(ctx, prices)
+	if err != nil {
+		return []product.AdjustedPrice{}, err
+	}
+	s.catalogChanged(ctx)
+	return res, nil
+}
+
+// adjustedPrices applies the adjustment to the prices each selected product has at adj.EffectiveFrom,
+// so an adjustment scheduled after another pending change builds on that change
+func (s *Service) adjustedPrices(ctx context.Context, adj product.PriceAdjustment) ([]product.AdjustedPrice, error) {
+	now := time.Now()
+	if adj.EffectiveFrom.IsZero() {
+		adj.EffectiveFrom = now
+	}
+	if adj.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return []product.AdjustedPrice{}, product.ErrInvalidEffectiveDate
+	}
+	if adj.RoundingMode == "" {
+		adj.RoundingMode = money.RoundNearest
+	}
+	if !validAdjustment(adj) {
+		return []product.AdjustedPrice{}, product.ErrInvalidAdjustment
+	}
+
+	products, err := s.adjustedProducts(ctx, adj)
+	if err != nil {
+		return []product.AdjustedPrice{}, err
+	}
+
+	res := make([]product.AdjustedPrice, 0, len(products))
+	for _, p := range products {
+		old, err := s.GetPriceAsOf(ctx, p.ID, adj.EffectiveFrom)
+		if err != nil {
+			return []product.AdjustedPrice{}, err
+		}
+		price := product.Price{
+			ProductID:            p.ID,
+			PriceStandard:        adjustPrice(old.PriceStandard, adj),
+			PriceExpressToday:    adjustPrice(old.PriceExpressToday, adj),
+			PriceExpressTomorrow: adjustPrice(old.PriceExpressTomorrow, adj),
+			EffectiveFrom:        adj.EffectiveFrom,
+		}
+		if !validPrices(price.PriceStandard, price.PriceExpressToday, price.PriceExpressTomorrow) {
+			return []product.AdjustedPrice{}, product.ErrInvalidPrice
+		}
+		res = append(res, product.AdjustedPrice{
+			ProductID:   p.ID,
+			ProductName: p.Name,
+			Old:         old,
+			New:         price,
+		})
+	}
+	return res, nil
+}
+
+// adjustedProducts returns the active products of the adjustment category or its product IDs
+func (s *Service) adjustedProducts(ctx context.Context, adj product.PriceAdjustment) ([]product.Product, error) {
+	if len(adj.ProductIDs) == 0 {
+		active := true
+		return s.store.GetAllProduct(ctx, product.Filter{Category: &adj.Category, Active: &active})
+	}
+
+	res := make([]product.Product, 0, len(adj.ProductIDs))
+	seen := make(map[int64]bool, len(adj.ProductIDs))
+	for _, ID := range adj.ProductIDs {
+		if seen[ID] {
+			continue
+		}
+		seen[ID] = true
+		p, err := s.store.GetProductByID(ctx, ID)
+		if err != nil {
+			return []product.Product{}, err
+		}
+		if !p.Active || (adj.Category != "" && p.Category != adj.Category) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func adjustPrice(price money.Money, adj product.PriceAdjustment) money.Money {
+	switch adj.Type {
+	case product.AdjustmentPercentage:
+		price += price.Percent(adj.Value)
+	case product.AdjustmentFixed:
+		price += money.FromFloat(adj.Value)
+	}
+	return price.Round(adj.RoundingUnit, adj.RoundingMode)
+}
+
+func validAdjustment(adj product.PriceAdjustment) bool {
+	if len(adj.ProductIDs) == 0 && !adj.Category.Valid() {
+		return false
+	}
+	if adj.Category != "" && !adj.Category.Valid() {
+		return false
+	}
+	if adj.Value == 0 || adj.RoundingUnit < 0 {
+		return false
+	}
+	if adj.Type == product.AdjustmentPercentage && adj.Value <= -100 {
+		return false
+	}
+	if adj.Type != product.AdjustmentPercentage && adj.Type != product.AdjustmentFixed {
+		return false
+	}
+	switch adj.RoundingMode {
+	case money.RoundNearest, money.RoundUp, money.RoundDown:
+		return true
+	}
+	return false
+}
+
+// DeactivateProduct retires a product, it is kept for the history but no longer listed as active
+func (s *Service) DeactivateProduct(ctx context.Context, ID int64) error {
+	_, err := s.store.GetProductByID(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.DeactivateProduct(ctx, ID)
+	if err != nil {
+		return err
+	}
+	s.catalogChanged(ctx)
+	return nil
+}
+
+func (s *Service) SetProductImage(ctx context.Context, ID int64, data []byte) (product.Product, error) {
+	if len(data) == 0 || len(data) > maxImageSize {
+		return product.Product{}, product.ErrInvalidImage
+	}
+	p, err := s.store.GetProductByID(ctx, ID)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		return product.Product{}, product.ErrInvalidImage
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return product.Product{}, product.ErrInvalidImage
+	}
+
+	// every upload gets new names so cached copies of the previous image are never served
+	base := fmt.Sprintf("product/%d/%d", ID, time.Now().UnixNano())
+	imagePath := base + "." + format
+
+	// jpeg thumbnails would lose the transparency of png and gif images
+	var thumb bytes.Buffer
+	thumbnailPath := base + "_thumb.png"
+	if format == "jpeg" {
+		thumbnailPath = base + "_thumb.jpeg"
+		err = jpeg.Encode(&thumb, imageutil.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&thumb, imageutil.Thumbnail(img, thumbnailSize))
+	}
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	err = s.files.Save(ctx, imagePath, bytes.NewReader(data))
+	if err != nil {
+		return product.Product{}, err
+	}
+	err = s.files.Save(ctx, thumbnailPath, &thumb)
+	if err != nil {
+		s.deleteFiles(ctx, imagePath)
+		return product.Product{}, err
+	}
+	err = s.store.UpdateProductImage(ctx, ID, imagePath, thumbnailPath)
+	if err != nil {
+		s.deleteFiles(ctx, imagePath, thumbnailPath)
+		return product.Product{}, err
+	}
+
+	s.catalogChanged(ctx)
+	s.deleteFiles(ctx, p.ImagePath, p.ThumbnailPath)
+	p.ImagePath = imagePath
+	p.ThumbnailPath = thumbnailPath
+	return p, nil
+}
+
+func (s *Service) GetProductImage(ctx context.Context, ID int64, thumbnail bool) (product.Image, error) {
+	p, err := s.store.GetProductByID(ctx, ID)
+	if err != nil {
+		return product.Image{}, err
+	}
+	name := p.ImagePath
+	if thumbnail {
+		name = p.ThumbnailPath
+	}
+	if name == "" {
+		return product.Image{}, product.ErrImageNotFound
+	}
+
+	f, err := s.files.Open(ctx, name)
+	if err == filestore.ErrNotFound {
+		return product.Image{}, product.ErrImageNotFound
+	}
+	if err != nil {
+		return product.Image{}, err
+	}
+	return product.Image{
+		Path:        name,
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		Content:     f,
+	}, nil
+}
+
+// deleteFiles removes files that are no longer referenced, failures only leave unused files behind
+func (s *Service) deleteFiles(ctx context.Context, names ...string) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		err := s.files.Delete(ctx, name)
+		if err != nil {
+			log.Printf("[Product][Service] failed to delete file, name: %s, err:%v\n", name, err)
+		}
+	}
+}
+
+func (s *Service) validateProduct(ctx context.Context, p product.Product) error {
+	if len(p.Name) == 0 || len(p.Name) > maxProductNameLength {
+		return product.ErrInvalidProduct
+	}
+	if !validPrices(p.PriceStandard, p.PriceExpressToday, p.PriceExpressTomorrow) {
+		return product.ErrInvalidPrice
+	}
+	if !p.Unit.Valid() {
+		return product.ErrInvalidUnit
+	}
+	if !p.Category.Valid() {
+		return product.ErrInvalidCategory
+	}
+	if p.MinQuantity < 0 || p.QuantityStep < 0 {
+		return product.ErrInvalidQuantityRule
+	}
+	if p.SortOrder < 0 || len(p.Icon) > maxIconLength || (p.Color != "" && !colorPattern.MatchString(p.Color)) {
+		return product.ErrInvalidDisplay
+	}
+	if !p.Active {
+		return nil
+	}
+
+	existing, err := s.store.GetActiveProductByName(ctx, p.Name)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != p.ID {
+		return product.ErrDuplicateProduct
+	}
+	return nil
+}
+
+// normalizeUnit fills the unit and category of clients that only send is_satuan and keeps
+// is_satuan in line with the unit
+func normalizeUnit(p product.Product) product.Product {
+	if p.Unit == "" {
+		p.Unit = product.UnitKg
+		if p.IsSatuan {
+			p.Unit = product.UnitPiece
+		}
+	}
+	p.IsSatuan = p.Unit != product.UnitKg
+	if p.Category == "" {
+		p.Category = product.CategoryKiloan
+		if p.IsSatuan {
+			p.Category = product.CategorySatuan
+		}
+	}
+	return p
+}
+
+func validPrices(standard, expressToday, expressTomorrow money.Money) bool {
+	if standard < 0 || expressToday < 0 || expressTomorrow < 0 {
+		return false
+	}
+	return expressToday >= standard && expressTomorrow >= standard
+}
+
+func NewService(store Store, files filestore.Store, cfg Config) *Service {
 	return &Service{
 		store: store,
+		files: files,
+		cfg:   cfg,
 	}
 }
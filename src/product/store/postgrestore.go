@@ -2,14 +2,83 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/corneliusdavid97/laundry-go/src/product"
+	"github.com/corneliusdavid97/laundry-go/tools/querybuilder"
 	"github.com/jmoiron/sqlx"
 )
 
+// prices come from the customer override first, then the currently effective price history
+// entry, product_data prices are only the fallback for products without history
 const queryGetAllProduct = `
+	select
+		p.id,
+		p.product_name,
+		coalesce(cp.price_standard, h.price_standard, p.price_standard),
+		coalesce(cp.price_express_today, h.price_express_today, p.price_express_today),
+		coalesce(cp.price_express_tmr, h.price_express_tmr, p.price_express_tmr),
+		p.active,
+		p.is_satuan,
+		p.unit,
+		p.category,
+		p.min_quantity,
+		p.quantity_step,
+		p.sort_order,
+		p.color,
+		p.icon,
+		coalesce(p.image_path,''),
+		coalesce(p.thumbnail_path,'')
+	from
+		product_data p
+		left join product_customer_price cp on cp.product_id = p.id and cp.customer_id = $1
+		left join lateral (
+			select price_standard, price_express_today, price_express_tmr
+			from product_price_history
+			where product_id = p.id and effective_from <= now() and (effective_until is null or effective_until > now())
+			order by effective_from desc
+			limit 1
+		) h on true
+	where
+		%s
+`
+
+const queryGetProductByID = `
+	select
+		p.id,
+		p.product_name,
+		coalesce(h.price_standard, p.price_standard),
+		coalesce(h.price_express_today, p.price_express_today),
+		coalesce(h.price_express_tmr, p.price_express_tmr),
+		p.active,
+		p.is_satuan,
+		p.unit,
+		p.category,
+		p.min_quantity,
+		p.quantity_step,
+		p.sort_order,
+		p.color,
+		p.icon,
+		coalesce(p.image_path,''),
+		coalesce(p.thumbnail_path,'')
+	from
+		product_data p
+		left join lateral (
+			select price_standard, price_express_today, price_express_tmr
+			from product_price_history
+			where product_id = p.id and effective_from <= now() and (effective_until is null or effective_until > now())
+			order by effective_from desc
+			limit 1
+		) h on true
+	where
+		p.id=$1
+`
+
+const queryGetActiveProductByName = `
 	select
 		id,
 		product_name,
@@ -17,11 +86,271 @@ const queryGetAllProduct = `
 		price_express_today,
 		price_express_tmr,
 		active,
-		is_satuan
+		is_satuan,
+		unit,
+		category,
+		min_quantity,
+		quantity_step,
+		sort_order,
+		color,
+		icon,
+		coalesce(image_path,''),
+		coalesce(thumbnail_path,'')
 	from
 		product_data
 	where
-		%s
+		lower(product_name)=lower($1) and active=true
+	limit
+		1
+`
+
+const queryInsertProduct = `
+	insert into product_data(
+		product_name,
+		price_standard,
+		price_express_today,
+		price_express_tmr,
+		active,
+		is_satuan,
+		unit,
+		category,
+		min_quantity,
+		quantity_step,
+		sort_order,
+		color,
+		icon
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6,
+		$7,
+		$8,
+		$9,
+		$10,
+		$11,
+		$12,
+		$13
+	)
+	returning id
+`
+
+const queryUpdateProduct = `
+	update product_data set
+		product_name=$2,
+		price_standard=$3,
+		price_express_today=$4,
+		price_express_tmr=$5,
+		is_satuan=$6,
+		unit=$7,
+		category=$8,
+		min_quantity=$9,
+		quantity_step=$10,
+		sort_order=$11,
+		color=$12,
+		icon=$13
+	where
+		id=$1
+`
+
+const queryUpdateProductImage = `
+	update product_data set
+		image_path=nullif($2,''),
+		thumbnail_path=nullif($3,'')
+	where
+		id=$1
+`
+
+const queryDeactivateProduct = `
+	update product_data set
+		active=false
+	where
+		id=$1
+`
+
+const queryGetPriceHistory = `
+	select
+		id,
+		product_id,
+		price_standard,
+		price_express_today,
+		price_express_tmr,
+		effective_from,
+		effective_until
+	from
+		product_price_history
+	where
+		product_id=$1
+	order by
+		effective_from
+`
+
+const queryGetPriceAsOf = `
+	select
+		id,
+		product_id,
+		price_standard,
+		price_express_today,
+		price_express_tmr,
+		effective_from,
+		effective_until
+	from
+		product_price_history
+	where
+		product_id=$1 and effective_from <= $2 and (effective_until is null or effective_until > $2)
+	order by
+		effective_from desc
+	limit
+		1
+`
+
+const queryLockProduct = `
+	select
+		id
+	from
+		product_data
+	where
+		id=$1
+	for update
+`
+
+const queryDeletePriceAt = `
+	delete from product_price_history
+	where
+		product_id=$1 and effective_from=$2
+`
+
+const queryGetNextPriceStart = `
+	select
+		min(effective_from)
+	from
+		product_price_history
+	where
+		product_id=$1 and effective_from > $2
+`
+
+const queryClosePriceAt = `
+	update product_price_history set
+		effective_until=$2
+	where
+		product_id=$1 and effective_from < $2 and (effective_until is null or effective_until > $2)
+`
+
+const queryInsertPrice = `
+	insert into product_price_history(
+		product_id,
+		price_standard,
+		price_express_today,
+		price_express_tmr,
+		effective_from,
+		effective_until
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6
+	)
+`
+
+const queryGetCustomerPrices = `
+	select
+		cp.customer_id,
+		cp.product_id,
+		p.product_name,
+		cp.price_standard,
+		cp.price_express_today,
+		cp.price_express_tmr
+	from
+		product_customer_price cp
+		join product_data p on p.id = cp.product_id
+	where
+		cp.customer_id=$1
+	order by
+		p.product_name
+`
+
+const queryUpsertCustomerPrice = `
+	insert into product_customer_price(
+		customer_id,
+		product_id,
+		price_standard,
+		price_express_today,
+		price_express_tmr
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5
+	)
+	on conflict (customer_id, product_id) do update set
+		price_standard=excluded.price_standard,
+		price_express_today=excluded.price_express_today,
+		price_express_tmr=excluded.price_express_tmr
+`
+
+const queryDeleteCustomerPrice = `
+	delete from product_customer_price
+	where
+		customer_id=$1 and product_id=$2
+`
+
+const queryGetAddons = `
+	select
+		id,
+		product_id,
+		addon_name,
+		price_type,
+		price,
+		active
+	from
+		product_addon
+	where
+		($1::bigint is null or product_id=$1)
+		and ($2::boolean is null or active=$2)
+	order by
+		product_id, addon_name
+`
+
+const queryInsertAddon = `
+	insert into product_addon(
+		product_id,
+		addon_name,
+		price_type,
+		price,
+		active
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5
+	)
+	returning id
+`
+
+const queryDeactivateAddon = `
+	update product_addon set
+		active=false
+	where
+		id=$1
+`
+
+const queryGetNextPriceChange = `
+	select
+		min(effective_from)
+	from
+		product_price_history
+	where
+		effective_from > now()
+`
+
+const queryNotify = `
+	select pg_notify($1, '')
 `
 
 type Store struct {
@@ -33,15 +362,15 @@ func (s *Store) GetAllProduct(ctx context.Context, filter product.Filter) ([]pro
 	if err != nil {
 		return []product.Product{}, err
 	}
-	query := fmt.Sprintf(queryGetAllProduct, constructFilter(filter))
-	rows, err := db.QueryContext(ctx, query)
+	where, args := constructFilter(filter)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(queryGetAllProduct, where), args...)
 	if err != nil {
 		return []product.Product{}, err
 	}
 	res := make([]product.Product, 0)
 	for rows.Next() {
 		var p product.Product
-		err = rows.Scan(&p.ID, &p.Name, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow, &p.Active, &p.IsSatuan)
+		err = rows.Scan(&p.ID, &p.Name, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow, &p.Active, &p.IsSatuan, &p.Unit, &p.Category, &p.MinQuantity, &p.QuantityStep, &p.SortOrder, &p.Color, &p.Icon, &p.ImagePath, &p.ThumbnailPath)
 		if err != nil {
 			log.Printf("Failed to scan product, err:%v, product:%v", err, p)
 		}
@@ -50,26 +379,419 @@ func (s *Store) GetAllProduct(ctx context.Context, filter product.Filter) ([]pro
 	return res, nil
 }
 
-func constructFilter(filter product.Filter) string {
-	if (filter == product.Filter{}) {
-		return "active=true"
-	}
-	var filters []string
-	if filter.Active != nil {
-		filters = append(filters, fmt.Sprintf("active=%v", *filter.Active))
+// sortColumns maps the product sort fields to their columns in queryGetAllProduct
+var sortColumns = map[string]string{
+	product.SortID:       "p.id",
+	product.SortName:     "lower(p.product_name)",
+	product.SortPrice:    "coalesce(cp.price_standard, h.price_standard, p.price_standard)",
+	product.SortCategory: "p.category",
+	product.SortOrder:    "p.sort_order",
+}
+
+// constructFilter builds the where clause of queryGetAllProduct, $1 is the customer id of the price overrides
+func constructFilter(filter product.Filter) (string, []interface{}) {
+	b := querybuilder.New(filter.CustomerID)
+	if filter.Active == nil {
+		b.Where("p.active=true")
+	} else {
+		b.Where("p.active=?", *filter.Active)
 	}
 	if filter.IsSatuan != nil {
-		filters = append(filters, fmt.Sprintf("is_satuan=%v", *filter.IsSatuan))
+		b.Where("p.is_satuan=?", *filter.IsSatuan)
+	}
+	if filter.Category != nil {
+		b.Where("p.category=?", *filter.Category)
+	}
+	if filter.Unit != nil {
+		b.Where("p.unit=?", *filter.Unit)
+	}
+	if filter.Name != "" {
+		b.Where("p.product_name ilike ?", querybuilder.Like(filter.Name))
+	}
+	if filter.MinPrice != nil {
+		b.Where(sortColumns[product.SortPrice]+" >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		b.Where(sortColumns[product.SortPrice]+" <= ?", *filter.MaxPrice)
 	}
 
-	res := ""
-	for i, f := range filters {
-		if i != 0 {
-			res += "and "
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		column = sortColumns[product.SortOrder]
+	}
+	b.OrderBy(column, filter.SortDesc)
+	if column != sortColumns[product.SortID] {
+		b.OrderBy(sortColumns[product.SortID], false)
+	}
+	return b.Page(filter.Limit, filter.Offset).Build()
+}
+
+func (s *Store) GetProductByID(ctx context.Context, ID int64) (product.Product, error) {
+	return s.getProduct(ctx, queryGetProductByID, ID)
+}
+
+func (s *Store) GetActiveProductByName(ctx context.Context, name string) (product.Product, error) {
+	return s.getProduct(ctx, queryGetActiveProductByName, name)
+}
+
+func (s *Store) getProduct(ctx context.Context, query string, param interface{}) (product.Product, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	var p product.Product
+	err = db.QueryRowContext(ctx, query, param).Scan(&p.ID, &p.Name, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow, &p.Active, &p.IsSatuan, &p.Unit, &p.Category, &p.MinQuantity, &p.QuantityStep, &p.SortOrder, &p.Color, &p.Icon, &p.ImagePath, &p.ThumbnailPath)
+	if err != nil {
+		return product.Product{}, err
+	}
+	return p, nil
+}
+
+// InsertProduct inserts the product along with its first price history entry effective from effectiveFrom
+func (s *Store) InsertProduct(ctx context.Context, p product.Product, effectiveFrom time.Time) (int64, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, queryInsertProduct, p.Name, p.PriceStandard, p.PriceExpressToday, p.PriceExpressTomorrow, p.Active, p.IsSatuan, p.Unit, p.Category, p.MinQuantity, p.QuantityStep, p.SortOrder, p.Color, p.Icon).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	err = schedulePrice(ctx, tx, product.Price{
+		ProductID:            id,
+		PriceStandard:        p.PriceStandard,
+		PriceExpressToday:    p.PriceExpressToday,
+		PriceExpressTomorrow: p.PriceExpressTomorrow,
+		EffectiveFrom:        effectiveFrom,
+	})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateProduct updates the product, a non nil price is added to the price history in the same transaction
+func (s *Store) UpdateProduct(ctx context.Context, p product.Product, price *product.Price) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryUpdateProduct, p.ID, p.Name, p.PriceStandard, p.PriceExpressToday, p.PriceExpressTomorrow, p.IsSatuan, p.Unit, p.Category, p.MinQuantity, p.QuantityStep, p.SortOrder, p.Color, p.Icon)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if price != nil {
+		err = schedulePrice(ctx, tx, *price)
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
-		res += f + " "
 	}
-	return res
+
+	return tx.Commit()
+}
+
+func (s *Store) GetPriceHistory(ctx context.Context, productID int64) ([]product.Price, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []product.Price{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetPriceHistory, productID)
+	if err != nil {
+		return []product.Price{}, err
+	}
+	defer rows.Close()
+
+	res := make([]product.Price, 0)
+	for rows.Next() {
+		var p product.Price
+		err = rows.Scan(&p.ID, &p.ProductID, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow, &p.EffectiveFrom, &p.EffectiveUntil)
+		if err != nil {
+			return []product.Price{}, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) GetPriceAsOf(ctx context.Context, productID int64, at time.Time) (product.Price, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return product.Price{}, err
+	}
+
+	var p product.Price
+	err = db.QueryRowContext(ctx, queryGetPriceAsOf, productID, at).Scan(&p.ID, &p.ProductID, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow, &p.EffectiveFrom, &p.EffectiveUntil)
+	if err != nil {
+		return product.Price{}, err
+	}
+	return p, nil
+}
+
+func (s *Store) SchedulePriceChange(ctx context.Context, price product.Price) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	err = schedulePrice(ctx, tx, price)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SchedulePriceChanges schedules every price in one transaction, either all or none of them are applied
+func (s *Store) SchedulePriceChanges(ctx context.Context, prices []product.Price) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	// lock products in a fixed order so concurrent adjustments can't deadlock
+	sorted := make([]product.Price, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ProductID < sorted[j].ProductID
+	})
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	for _, price := range sorted {
+		err = schedulePrice(ctx, tx, price)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// schedulePrice inserts price into the history of its product, the entry effective at
+// price.EffectiveFrom is cut short and the new entry runs until the next scheduled change
+func schedulePrice(ctx context.Context, tx *sqlx.Tx, price product.Price) error {
+	// serialize changes of the same product
+	_, err := tx.ExecContext(ctx, queryLockProduct, price.ProductID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryDeletePriceAt, price.ProductID, price.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+
+	var nextStart *time.Time
+	err = tx.QueryRowContext(ctx, queryGetNextPriceStart, price.ProductID, price.EffectiveFrom).Scan(&nextStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryClosePriceAt, price.ProductID, price.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertPrice, price.ProductID, price.PriceStandard, price.PriceExpressToday, price.PriceExpressTomorrow, price.EffectiveFrom, nextStart)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) UpdateProductImage(ctx context.Context, ID int64, imagePath, thumbnailPath string) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, queryUpdateProductImage, ID, imagePath, thumbnailPath)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetNextPriceChange returns when the next scheduled price becomes effective, nil when none is scheduled
+func (s *Store) GetNextPriceChange(ctx context.Context) (*time.Time, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return nil, err
+	}
+
+	var res *time.Time
+	err = db.QueryRowContext(ctx, queryGetNextPriceChange).Scan(&res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) Notify(ctx context.Context, channel string) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, queryNotify, channel)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) DeactivateProduct(ctx context.Context, ID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, queryDeactivateProduct, ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) GetAddons(ctx context.Context, filter product.AddonFilter) ([]product.Addon, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []product.Addon{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetAddons, filter.ProductID, filter.Active)
+	if err != nil {
+		return []product.Addon{}, err
+	}
+	defer rows.Close()
+
+	res := make([]product.Addon, 0)
+	for rows.Next() {
+		var a product.Addon
+		err = rows.Scan(&a.ID, &a.ProductID, &a.Name, &a.PriceType, &a.Price, &a.Active)
+		if err != nil {
+			return []product.Addon{}, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) InsertAddon(ctx context.Context, addon product.Addon) (int64, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = db.QueryRowContext(ctx, queryInsertAddon, addon.ProductID, addon.Name, addon.PriceType, addon.Price, addon.Active).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *Store) DeactivateAddon(ctx context.Context, ID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, queryDeactivateAddon, ID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetCustomerPrices(ctx context.Context, customerID int64) ([]product.CustomerPrice, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []product.CustomerPrice{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetCustomerPrices, customerID)
+	if err != nil {
+		return []product.CustomerPrice{}, err
+	}
+	defer rows.Close()
+
+	res := make([]product.CustomerPrice, 0)
+	for rows.Next() {
+		var p product.CustomerPrice
+		err = rows.Scan(&p.CustomerID, &p.ProductID, &p.ProductName, &p.PriceStandard, &p.PriceExpressToday, &p.PriceExpressTomorrow)
+		if err != nil {
+			log.Printf("Failed to scan customer price, err:%v, price:%v", err, p)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (s *Store) UpsertCustomerPrice(ctx context.Context, price product.CustomerPrice) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, queryUpsertCustomerPrice, price.CustomerID, price.ProductID, price.PriceStandard, price.PriceExpressToday, price.PriceExpressTomorrow)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) DeleteCustomerPrice(ctx context.Context, customerID, productID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, queryDeleteCustomerPrice, customerID, productID)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
 func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
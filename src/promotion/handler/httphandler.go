@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
+	"github.com/corneliusdavid97/laundry-go/src/user"
+	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/timer"
+)
+
+type HTTPHandler struct {
+	svc promotion.Service
+	cfg Config
+}
+
+type Config struct {
+	Timeout time.Duration
+}
+
+type Promotion struct {
+	ID             int64       `json:"id"`
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	Value          float64     `json:"value"`
+	MaxDiscount    money.Money `json:"max_discount"`
+	MinSpend       money.Money `json:"min_spend"`
+	ProductName    string      `json:"product_name"`
+	BuyQuantity    float64     `json:"buy_quantity"`
+	FreeQuantity   float64     `json:"free_quantity"`
+	CouponCode     string      `json:"coupon_code"`
+	UsageLimit     int         `json:"usage_limit"`
+	UsageCount     int         `json:"usage_count"`
+	ValidFrom      *string     `json:"valid_from"`
+	ValidUntil     *string     `json:"valid_until"`
+	HappyHourStart string      `json:"happy_hour_start"`
+	HappyHourEnd   string      `json:"happy_hour_end"`
+	Active         bool        `json:"active"`
+	CreatedBy      string      `json:"created_by"`
+	CreatedAt      string      `json:"created_at"`
+}
+
+type NewPromotionParam struct {
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	Value          float64     `json:"value"`
+	MaxDiscount    money.Money `json:"max_discount"`
+	MinSpend       money.Money `json:"min_spend"`
+	ProductName    string      `json:"product_name"`
+	BuyQuantity    float64     `json:"buy_quantity"`
+	FreeQuantity   float64     `json:"free_quantity"`
+	CouponCode     string      `json:"coupon_code"`
+	UsageLimit     int         `json:"usage_limit"`
+	ValidFromStr   string      `json:"valid_from"`
+	ValidUntilStr  string      `json:"valid_until"`
+	HappyHourStart string      `json:"happy_hour_start"`
+	HappyHourEnd   string      `json:"happy_hour_end"`
+}
+
+func (h *HTTPHandler) HandleGetAllPromotions(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	promos, err := h.svc.GetAllPromotions(ctx)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	res := make([]Promotion, 0, len(promos))
+	for _, p := range promos {
+		res = append(res, parsePromotion(p))
+	}
+
+	httputil.WriteDataResponse(w, res, len(res), t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleNewPromotion(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var request NewPromotionParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	p := promotion.Promotion{
+		Name:           request.Name,
+		Type:           promotion.Type(request.Type),
+		Value:          request.Value,
+		MaxDiscount:    request.MaxDiscount,
+		MinSpend:       request.MinSpend,
+		ProductName:    request.ProductName,
+		BuyQuantity:    request.BuyQuantity,
+		FreeQuantity:   request.FreeQuantity,
+		CouponCode:     request.CouponCode,
+		UsageLimit:     request.UsageLimit,
+		HappyHourStart: request.HappyHourStart,
+		HappyHourEnd:   request.HappyHourEnd,
+	}
+	p.ValidFrom, err = parseTime(request.ValidFromStr)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+	p.ValidUntil, err = parseTime(request.ValidUntilStr)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+	if u, ok := user.FromContext(r.Context()); ok {
+		p.CreatedBy = u.Username
+	}
+
+	res, err := h.svc.CreatePromotion(ctx, p)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteDataResponse(w, parsePromotion(res), 1, t.GetElapsedTime())
+}
+
+func (h *HTTPHandler) HandleDeactivatePromotion(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httputil.BadRequest(err)})
+		return
+	}
+
+	err = h.svc.DeactivatePromotion(ctx, id)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{parseError(err)})
+		return
+	}
+
+	httputil.WriteSuccessResponse(w, "Promotion deactivated", t.GetElapsedTime())
+}
+
+func parseError(err error) httputil.ErrorResponse {
+	status := http.StatusInternalServerError
+	switch err {
+	case promotion.ErrInvalidPromotion:
+		status = http.StatusBadRequest
+	case promotion.ErrPromotionNotFound:
+		status = http.StatusNotFound
+	case promotion.ErrDuplicateCoupon:
+		status = http.StatusConflict
+	}
+	return httputil.NewErrorResponse(status, err)
+}
+
+func parsePromotion(p promotion.Promotion) Promotion {
+	return Promotion{
+		ID:             p.ID,
+		Name:           p.Name,
+		Type:           string(p.Type),
+		Value:          p.Value,
+		MaxDiscount:    p.MaxDiscount,
+		MinSpend:       p.MinSpend,
+		ProductName:    p.ProductName,
+		BuyQuantity:    p.BuyQuantity,
+		FreeQuantity:   p.FreeQuantity,
+		CouponCode:     p.CouponCode,
+		UsageLimit:     p.UsageLimit,
+		UsageCount:     p.UsageCount,
+		ValidFrom:      httputil.FormatTime(p.ValidFrom, "2006-01-02 15:04:05"),
+		ValidUntil:     httputil.FormatTime(p.ValidUntil, "2006-01-02 15:04:05"),
+		HappyHourStart: p.HappyHourStart,
+		HappyHourEnd:   p.HappyHourEnd,
+		Active:         p.Active,
+		CreatedBy:      p.CreatedBy,
+		CreatedAt:      p.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func NewHandler(svc promotion.Service, cfg Config) *HTTPHandler {
+	return &HTTPHandler{
+		svc: svc,
+		cfg: cfg,
+	}
+}
@@ -0,0 +1,96 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+type Type string
+
+const (
+	// TypePercentage takes Value percent off the eligible amount, capped at MaxDiscount when set
+	TypePercentage Type = "percentage"
+	// TypeFixed takes Value off the eligible amount
+	TypeFixed Type = "fixed"
+	// TypeBuyGetFree makes FreeQuantity free for every BuyQuantity + FreeQuantity of an eligible line,
+	// e.g. buy 5 kg get 1 kg free
+	TypeBuyGetFree Type = "buy_get_free"
+)
+
+// Promotion is an admin defined discount rule, promotions without a coupon code apply
+// automatically to every order they match
+type Promotion struct {
+	ID   int64
+	Name string
+	Type Type
+	// Value is the percentage of a percentage promotion or the rupiah amount of a fixed one
+	Value float64
+	// MaxDiscount caps a percentage discount, zero means no cap
+	MaxDiscount money.Money
+	// MinSpend is the order subtotal required before the promotion applies
+	MinSpend money.Money
+	// ProductName limits the promotion to one product, empty applies it to every product
+	ProductName  string
+	BuyQuantity  float64
+	FreeQuantity float64
+	CouponCode   string
+	// UsageLimit is the number of orders a coupon can be used on, zero means unlimited
+	UsageLimit int
+	UsageCount int
+	ValidFrom  *time.Time
+	ValidUntil *time.Time
+	// HappyHourStart and HappyHourEnd limit the promotion to a time of day in "15:04" format,
+	// empty applies it all day
+	HappyHourStart string
+	HappyHourEnd   string
+	Active         bool
+	CreatedBy      string
+	CreatedAt      time.Time
+}
+
+// Item is an order line evaluated against the promotions
+type Item struct {
+	ProductName string
+	Price       money.Money
+	Quantity    float64
+	Subtotal    money.Money
+}
+
+// Discount is the amount a promotion takes off an order, ProductName is set when the
+// promotion only applies to one product
+type Discount struct {
+	PromotionID int64
+	Name        string
+	ProductName string
+	CouponCode  string
+	Amount      money.Money
+}
+
+var ErrInvalidPromotion = errors.New("Invalid promotion data")
+var ErrDuplicateCoupon = errors.New("Another promotion already uses this coupon code")
+var ErrPromotionNotFound = errors.New("Promotion not found")
+var ErrInvalidCoupon = errors.New("Coupon code is invalid or not valid at this time")
+var ErrCouponUsedUp = errors.New("Coupon has reached its usage limit")
+var ErrMinSpendNotMet = errors.New("Order does not reach the coupon minimum spend")
+
+type Service interface {
+	GetAllPromotions(ctx context.Context) ([]Promotion, error)
+	CreatePromotion(ctx context.Context, p Promotion) (Promotion, error)
+	DeactivatePromotion(ctx context.Context, ID int64) error
+	// Apply returns the discounts of the automatic promotions matching the order at the given time
+	// and of the coupon when couponCode is not empty
+	Apply(ctx context.Context, items []Item, couponCode string, at time.Time) ([]Discount, error)
+}
+
+var defaultService Service
+
+func Init(s Service) {
+	defaultService = s
+}
+
+func GetService() Service {
+	return defaultService
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+type Service struct {
+	store Store
+}
+
+type Store interface {
+	GetAllPromotions(ctx context.Context) ([]promotion.Promotion, error)
+	GetAutomaticPromotions(ctx context.Context, at time.Time) ([]promotion.Promotion, error)
+	GetPromotionByCoupon(ctx context.Context, couponCode string) (promotion.Promotion, error)
+	InsertPromotion(ctx context.Context, p promotion.Promotion) (promotion.Promotion, error)
+	DeactivatePromotion(ctx context.Context, ID int64) error
+}
+
+func (s *Service) GetAllPromotions(ctx context.Context) ([]promotion.Promotion, error) {
+	res, err := s.store.GetAllPromotions(ctx)
+	if err != nil {
+		return []promotion.Promotion{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) CreatePromotion(ctx context.Context, p promotion.Promotion) (promotion.Promotion, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.ProductName = strings.TrimSpace(p.ProductName)
+	p.CouponCode = normalizeCoupon(p.CouponCode)
+	if !validPromotion(p) {
+		return promotion.Promotion{}, promotion.ErrInvalidPromotion
+	}
+
+	if p.CouponCode != "" {
+		_, err := s.store.GetPromotionByCoupon(ctx, p.CouponCode)
+		if err == nil {
+			return promotion.Promotion{}, promotion.ErrDuplicateCoupon
+		}
+		if err != sql.ErrNoRows {
+			return promotion.Promotion{}, err
+		}
+	}
+
+	res, err := s.store.InsertPromotion(ctx, p)
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) DeactivatePromotion(ctx context.Context, ID int64) error {
+	err := s.store.DeactivatePromotion(ctx, ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Apply stacks every matching automatic promotion and the coupon, each discount is computed
+// on the undiscounted items and the total discount never exceeds the order subtotal
+func (s *Service) Apply(ctx context.Context, items []promotion.Item, couponCode string, at time.Time) ([]promotion.Discount, error) {
+	var subtotal money.Money
+	for _, item := range items {
+		subtotal += item.Subtotal
+	}
+
+	promos, err := s.store.GetAutomaticPromotions(ctx, at)
+	if err != nil {
+		return []promotion.Discount{}, err
+	}
+
+	couponCode = normalizeCoupon(couponCode)
+	if couponCode != "" {
+		coupon, err := s.store.GetPromotionByCoupon(ctx, couponCode)
+		if err == sql.ErrNoRows {
+			return []promotion.Discount{}, promotion.ErrInvalidCoupon
+		}
+		if err != nil {
+			return []promotion.Discount{}, err
+		}
+		if !coupon.Active || !inValidity(coupon, at) || !inHappyHour(coupon, at) {
+			return []promotion.Discount{}, promotion.ErrInvalidCoupon
+		}
+		if coupon.UsageLimit > 0 && coupon.UsageCount >= coupon.UsageLimit {
+			return []promotion.Discount{}, promotion.ErrCouponUsedUp
+		}
+		if subtotal < coupon.MinSpend {
+			return []promotion.Discount{}, promotion.ErrMinSpendNotMet
+		}
+		promos = append(promos, coupon)
+	}
+
+	res := make([]promotion.Discount, 0)
+	remaining := subtotal
+	for _, p := range promos {
+		if !inHappyHour(p, at) || subtotal < p.MinSpend {
+			continue
+		}
+		amount := money.Min(discountAmount(p, items), remaining)
+		if amount <= 0 {
+			continue
+		}
+		remaining -= amount
+		res = append(res, promotion.Discount{
+			PromotionID: p.ID,
+			Name:        p.Name,
+			ProductName: p.ProductName,
+			CouponCode:  p.CouponCode,
+			Amount:      amount,
+		})
+	}
+	return res, nil
+}
+
+// discountAmount computes the discount of a promotion on its eligible items
+func discountAmount(p promotion.Promotion, items []promotion.Item) money.Money {
+	var eligible, free money.Money
+	for _, item := range items {
+		if p.ProductName != "" && !strings.EqualFold(p.ProductName, item.ProductName) {
+			continue
+		}
+		eligible += item.Subtotal
+		if p.Type == promotion.TypeBuyGetFree {
+			sets := math.Floor(item.Quantity / (p.BuyQuantity + p.FreeQuantity))
+			free += item.Price.Mul(sets * p.FreeQuantity)
+		}
+	}
+
+	switch p.Type {
+	case promotion.TypePercentage:
+		amount := eligible.Percent(p.Value)
+		if p.MaxDiscount > 0 {
+			amount = money.Min(amount, p.MaxDiscount)
+		}
+		return amount
+	case promotion.TypeFixed:
+		return money.Min(money.FromFloat(p.Value), eligible)
+	case promotion.TypeBuyGetFree:
+		return free
+	}
+	return 0
+}
+
+func inValidity(p promotion.Promotion, at time.Time) bool {
+	if p.ValidFrom != nil && at.Before(*p.ValidFrom) {
+		return false
+	}
+	if p.ValidUntil != nil && !at.Before(*p.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// inHappyHour reports whether at falls within the promotion time of day, a window whose
+// end is before its start runs past midnight
+func inHappyHour(p promotion.Promotion, at time.Time) bool {
+	if p.HappyHourStart == "" {
+		return true
+	}
+	start, _ := minuteOfDay(p.HappyHourStart)
+	end, _ := minuteOfDay(p.HappyHourEnd)
+	now := at.Hour()*60 + at.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+func minuteOfDay(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func validPromotion(p promotion.Promotion) bool {
+	if len(p.Name) == 0 || p.MinSpend < 0 || p.MaxDiscount < 0 || p.UsageLimit < 0 {
+		return false
+	}
+	switch p.Type {
+	case promotion.TypePercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			return false
+		}
+	case promotion.TypeFixed:
+		if p.Value <= 0 {
+			return false
+		}
+	case promotion.TypeBuyGetFree:
+		if p.BuyQuantity <= 0 || p.FreeQuantity <= 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		return false
+	}
+	if p.HappyHourStart != "" || p.HappyHourEnd != "" {
+		_, okStart := minuteOfDay(p.HappyHourStart)
+		_, okEnd := minuteOfDay(p.HappyHourEnd)
+		if !okStart || !okEnd || p.HappyHourStart == p.HappyHourEnd {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeCoupon makes coupon codes case insensitive
+func normalizeCoupon(couponCode string) string {
+	return strings.ToUpper(strings.TrimSpace(couponCode))
+}
+
+func NewService(store Store) *Service {
+	return &Service{
+		store: store,
+	}
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
+)
+
+const promotionColumns = `
+		id,
+		name,
+		promotion_type,
+		value,
+		max_discount,
+		min_spend,
+		coalesce(product_name,''),
+		buy_quantity,
+		free_quantity,
+		coalesce(coupon_code,''),
+		usage_limit,
+		usage_count,
+		valid_from,
+		valid_until,
+		coalesce(happy_hour_start,''),
+		coalesce(happy_hour_end,''),
+		active,
+		created_by,
+		created_at
+`
+
+const queryGetAllPromotions = `
+	select` + promotionColumns + `
+	from
+		promotion_data
+	order by
+		created_at desc
+`
+
+const queryGetAutomaticPromotions = `
+	select` + promotionColumns + `
+	from
+		promotion_data
+	where
+		active=true
+		and coupon_code is null
+		and (valid_from is null or valid_from <= $1)
+		and (valid_until is null or valid_until > $1)
+	order by
+		id
+`
+
+const queryGetPromotionByCoupon = `
+	select` + promotionColumns + `
+	from
+		promotion_data
+	where
+		coupon_code=$1
+`
+
+const queryInsertPromotion = `
+	insert into promotion_data(
+		name,
+		promotion_type,
+		value,
+		max_discount,
+		min_spend,
+		product_name,
+		buy_quantity,
+		free_quantity,
+		coupon_code,
+		usage_limit,
+		valid_from,
+		valid_until,
+		happy_hour_start,
+		happy_hour_end,
+		created_by
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		nullif($6,''),
+		$7,
+		$8,
+		nullif($9,''),
+		$10,
+		$11,
+		$12,
+		nullif($13,''),
+		nullif($14,''),
+		$15
+	)
+	returning id, created_at
+`
+
+const queryDeactivatePromotion = `
+	update promotion_data set
+		active=false
+	where
+		id=$1
+`
+
+type Store struct {
+	getDB func(dbName, replication string) (*sqlx.DB, error)
+}
+
+func (s *Store) GetAllPromotions(ctx context.Context) ([]promotion.Promotion, error) {
+	return s.getPromotions(ctx, queryGetAllPromotions)
+}
+
+func (s *Store) GetAutomaticPromotions(ctx context.Context, at time.Time) ([]promotion.Promotion, error) {
+	return s.getPromotions(ctx, queryGetAutomaticPromotions, at)
+}
+
+func (s *Store) getPromotions(ctx context.Context, query string, params ...interface{}) ([]promotion.Promotion, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []promotion.Promotion{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return []promotion.Promotion{}, err
+	}
+	defer rows.Close()
+
+	res := make([]promotion.Promotion, 0)
+	for rows.Next() {
+		var p promotion.Promotion
+		err = rows.Scan(&p.ID, &p.Name, &p.Type, &p.Value, &p.MaxDiscount, &p.MinSpend, &p.ProductName, &p.BuyQuantity, &p.FreeQuantity, &p.CouponCode, &p.UsageLimit, &p.UsageCount, &p.ValidFrom, &p.ValidUntil, &p.HappyHourStart, &p.HappyHourEnd, &p.Active, &p.CreatedBy, &p.CreatedAt)
+		if err != nil {
+			return []promotion.Promotion{}, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func (s *Store) GetPromotionByCoupon(ctx context.Context, couponCode string) (promotion.Promotion, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+
+	var p promotion.Promotion
+	err = db.QueryRowContext(ctx, queryGetPromotionByCoupon, couponCode).Scan(&p.ID, &p.Name, &p.Type, &p.Value, &p.MaxDiscount, &p.MinSpend, &p.ProductName, &p.BuyQuantity, &p.FreeQuantity, &p.CouponCode, &p.UsageLimit, &p.UsageCount, &p.ValidFrom, &p.ValidUntil, &p.HappyHourStart, &p.HappyHourEnd, &p.Active, &p.CreatedBy, &p.CreatedAt)
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+	return p, nil
+}
+
+func (s *Store) InsertPromotion(ctx context.Context, p promotion.Promotion) (promotion.Promotion, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+
+	err = db.QueryRowContext(ctx, queryInsertPromotion, p.Name, p.Type, p.Value, p.MaxDiscount, p.MinSpend, p.ProductName, p.BuyQuantity, p.FreeQuantity, p.CouponCode, p.UsageLimit, p.ValidFrom, p.ValidUntil, p.HappyHourStart, p.HappyHourEnd, p.CreatedBy).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+	p.Active = true
+	return p, nil
+}
+
+func (s *Store) DeactivatePromotion(ctx context.Context, ID int64) error {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, queryDeactivatePromotion, ID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return promotion.ErrPromotionNotFound
+	}
+	return nil
+}
+
+func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
+	return &Store{
+		getDB: getDB,
+	}
+}
@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/report"
+	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/timer"
+)
+
+type HTTPHandler struct {
+	svc report.Service
+	cfg Config
+}
+
+type Config struct {
+	Timeout time.Duration
+}
+
+type RFMScore struct {
+	CustomerID      int64       `json:"customer_id"`
+	CustomerName    string      `json:"customer_name"`
+	PhoneNumber     string      `json:"phone_number"`
+	LastTransaction string      `json:"last_transaction"`
+	RecencyDays     int         `json:"recency_days"`
+	Frequency       int64       `json:"frequency"`
+	Monetary        money.Money `json:"monetary"`
+	RecencyScore    int         `json:"recency_score"`
+	FrequencyScore  int         `json:"frequency_score"`
+	MonetaryScore   int         `json:"monetary_score"`
+	Segment         string      `json:"segment"`
+}
+
+type SalesItem struct {
+	Type        string      `json:"type"`
+	ProductID   *int64      `json:"product_id"`
+	ProductName string      `json:"product_name"`
+	AddonName   string      `json:"addon_name,omitempty"`
+	Lines       int64       `json:"lines"`
+	Quantity    float64     `json:"quantity"`
+	Revenue     money.Money `json:"revenue"`
+}
+
+type TaxSummary struct {
+	Month        string      `json:"month"`
+	OutletCode   string      `json:"outlet_code"`
+	Name         string      `json:"name"`
+	Kind         string      `json:"kind"`
+	Rate         float64     `json:"rate"`
+	Inclusive    bool        `json:"inclusive"`
+	Transactions int64       `json:"transactions"`
+	Base         money.Money `json:"base"`
+	Amount       money.Money `json:"amount"`
+}
+
+func (h *HTTPHandler) HandleGetRFMReport(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	filter := report.RFMFilter{
+		Segment: r.FormValue("segment"),
+	}
+	if sSince := r.FormValue("since"); len(sSince) > 0 {
+		since, err := time.ParseInLocation("2006-01-02", sSince, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		filter.Since = &since
+	}
+	if sAsOf := r.FormValue("as_of"); len(sAsOf) > 0 {
+		asOf, err := time.ParseInLocation("2006-01-02", sAsOf, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		// include the whole as_of day
+		filter.AsOf = asOf.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	scores, err := h.svc.GetRFMReport(ctx, filter)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res := make([]RFMScore, 0, len(scores))
+	for _, s := range scores {
+		res = append(res, parseRFMScore(s))
+	}
+
+	if r.FormValue("format") == "csv" {
+		filename := "rfm.csv"
+		if filter.Segment != "" {
+			filename = fmt.Sprintf("rfm-%s.csv", filter.Segment)
+		}
+		httputil.WriteCSVResponse(w, filename, rfmScoresToCSV(res))
+		return
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+// HandleGetSalesReport reports sales between the from and to dates inclusive, defaulting to the current month
+func (h *HTTPHandler) HandleGetSalesReport(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	now := time.Now()
+	filter := report.SalesFilter{
+		From: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local),
+	}
+	if sFrom := r.FormValue("from"); len(sFrom) > 0 {
+		from, err := time.ParseInLocation("2006-01-02", sFrom, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		filter.From = from
+	}
+	if sTo := r.FormValue("to"); len(sTo) > 0 {
+		to, err := time.ParseInLocation("2006-01-02", sTo, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		// include the whole to day
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	items, err := h.svc.GetSalesReport(ctx, filter)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res := make([]SalesItem, 0, len(items))
+	for _, item := range items {
+		res = append(res, SalesItem{
+			Type:        string(item.Type),
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			AddonName:   item.AddonName,
+			Lines:       item.Lines,
+			Quantity:    item.Quantity,
+			Revenue:     item.Revenue,
+		})
+	}
+
+	if r.FormValue("format") == "csv" {
+		filename := fmt.Sprintf("sales-%s-%s.csv", filter.From.Format("20060102"), filter.To.AddDate(0, 0, -1).Format("20060102"))
+		httputil.WriteCSVResponse(w, filename, salesItemsToCSV(res))
+		return
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+// HandleGetTaxSummary reports tax and charge totals per month between the from and to
+// months inclusive, defaulting to the current month
+func (h *HTTPHandler) HandleGetTaxSummary(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := from
+	if sFrom := r.FormValue("from"); len(sFrom) > 0 {
+		month, err := time.ParseInLocation("2006-01", sFrom, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		from = month
+		to = month
+	}
+	if sTo := r.FormValue("to"); len(sTo) > 0 {
+		month, err := time.ParseInLocation("2006-01", sTo, time.Local)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+		}
+		to = month
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	filter := report.TaxFilter{
+		From: from,
+		// include the whole to month
+		To: to.AddDate(0, 1, 0),
+	}
+
+	summaries, err := h.svc.GetTaxSummary(ctx, filter)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusInternalServerError,
+			Title:      http.StatusText(http.StatusInternalServerError),
+			Detail:     err.Error(),
+		})
+	}
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res := make([]TaxSummary, 0, len(summaries))
+	for _, s := range summaries {
+		res = append(res, TaxSummary{
+			Month:        s.Month.Format("2006-01"),
+			OutletCode:   s.OutletCode,
+			Name:         s.Name,
+			Kind:         s.Kind,
+			Rate:         s.Rate,
+			Inclusive:    s.Inclusive,
+			Transactions: s.Transactions,
+			Base:         s.Base,
+			Amount:       s.Amount,
+		})
+	}
+
+	if r.FormValue("format") == "csv" {
+		filename := fmt.Sprintf("tax-%s-%s.csv", from.Format("200601"), to.Format("200601"))
+		httputil.WriteCSVResponse(w, filename, taxSummariesToCSV(res))
+		return
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func taxSummariesToCSV(summaries []TaxSummary) [][]string {
+	records := [][]string{
+		{"month", "outlet_code", "name", "kind", "rate", "inclusive", "transactions", "base", "amount"},
+	}
+	for _, s := range summaries {
+		records = append(records, []string{
+			s.Month,
+			s.OutletCode,
+			s.Name,
+			s.Kind,
+			strconv.FormatFloat(s.Rate, 'f', -1, 64),
+			strconv.FormatBool(s.Inclusive),
+			strconv.FormatInt(s.Transactions, 10),
+			s.Base.String(),
+			s.Amount.String(),
+		})
+	}
+	return records
+}
+
+func salesItemsToCSV(items []SalesItem) [][]string {
+	records := [][]string{
+		{"type", "product_id", "product_name", "addon_name", "lines", "quantity", "revenue"},
+	}
+	for _, item := range items {
+		productID := ""
+		if item.ProductID != nil {
+			productID = strconv.FormatInt(*item.ProductID, 10)
+		}
+		records = append(records, []string{
+			item.Type,
+			productID,
+			item.ProductName,
+			item.AddonName,
+			strconv.FormatInt(item.Lines, 10),
+			strconv.FormatFloat(item.Quantity, 'f', -1, 64),
+			item.Revenue.String(),
+		})
+	}
+	return records
+}
+
+func parseRFMScore(s report.RFMScore) RFMScore {
+	return RFMScore{
+		CustomerID:      s.CustomerID,
+		CustomerName:    s.CustomerName,
+		PhoneNumber:     s.PhoneNumber,
+		LastTransaction: s.LastTransaction.Format("2006-01-02 15:04:05"),
+		RecencyDays:     s.RecencyDays,
+		Frequency:       s.Frequency,
+		Monetary:        s.Monetary,
+		RecencyScore:    s.RecencyScore,
+		FrequencyScore:  s.FrequencyScore,
+		MonetaryScore:   s.MonetaryScore,
+		Segment:         s.Segment,
+	}
+}
+
+func rfmScoresToCSV(scores []RFMScore) [][]string {
+	records := [][]string{
+		{"customer_id", "customer_name", "phone_number", "last_transaction", "recency_days", "frequency", "monetary", "recency_score", "frequency_score", "monetary_score", "segment"},
+	}
+	for _, s := range scores {
+		records = append(records, []string{
+			strconv.FormatInt(s.CustomerID, 10),
+			s.CustomerName,
+			s.PhoneNumber,
+			s.LastTransaction,
+			strconv.Itoa(s.RecencyDays),
+			strconv.FormatInt(s.Frequency, 10),
+			s.Monetary.String(),
+			strconv.Itoa(s.RecencyScore),
+			strconv.Itoa(s.FrequencyScore),
+			strconv.Itoa(s.MonetaryScore),
+			s.Segment,
+		})
+	}
+	return records
+}
+
+func NewHandler(svc report.Service, cfg Config) *HTTPHandler {
+	return &HTTPHandler{
+		svc: svc,
+		cfg: cfg,
+	}
+}
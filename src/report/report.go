@@ -0,0 +1,101 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+// CustomerActivity is the aggregated transaction history of a customer
+type CustomerActivity struct {
+	CustomerID      int64
+	CustomerName    string
+	PhoneNumber     string
+	LastTransaction time.Time
+	Frequency       int64
+	Monetary        money.Money
+}
+
+// RFMScore is the recency, frequency and monetary score of a customer
+type RFMScore struct {
+	CustomerActivity
+	RecencyDays    int
+	RecencyScore   int
+	FrequencyScore int
+	MonetaryScore  int
+	Segment        string
+}
+
+type RFMFilter struct {
+	// Segment limits the result to a single segment, empty means all segments
+	Segment string
+	// Since ignores transactions before it when set
+	Since *time.Time
+	// AsOf is the reference time recency is computed against, zero means now
+	AsOf time.Time
+}
+
+type SalesItemType string
+
+const (
+	SalesItemProduct SalesItemType = "product"
+	SalesItemAddon   SalesItemType = "addon"
+)
+
+// SalesItem is the gross sales of a product or of an add-on of a product, before transaction discounts
+type SalesItem struct {
+	Type SalesItemType
+	// ProductID is nil for lines made before transaction lines were linked to the catalog
+	ProductID   *int64
+	ProductName string
+	// AddonName is empty for products
+	AddonName string
+	Lines     int64
+	Quantity  float64
+	Revenue   money.Money
+}
+
+// SalesFilter limits the sales report to transactions within [From, To)
+type SalesFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// TaxSummary is the monthly total of a tax or charge rule at an outlet
+type TaxSummary struct {
+	Month        time.Time
+	OutletCode   string
+	Name         string
+	Kind         string
+	Rate         float64
+	Inclusive    bool
+	Transactions int64
+	Base         money.Money
+	Amount       money.Money
+}
+
+// TaxFilter limits the tax summary to the months within [From, To)
+type TaxFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// SegmentOthers is assigned to customers not matching any configured segment
+const SegmentOthers = "others"
+
+type Service interface {
+	GetRFMReport(ctx context.Context, filter RFMFilter) ([]RFMScore, error)
+	GetSalesReport(ctx context.Context, filter SalesFilter) ([]SalesItem, error)
+	GetTaxSummary(ctx context.Context, filter TaxFilter) ([]TaxSummary, error)
+}
+
+var defaultService Service
+
+func Init(s Service) {
+	defaultService = s
+}
+
+func GetService() Service {
+	return defaultService
+}
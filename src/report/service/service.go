@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/src/config"
+	"github.com/corneliusdavid97/laundry-go/src/report"
+)
+
+type Service struct {
+	store  Store
+	rfmCfg config.RFMConfig
+}
+
+type Store interface {
+	GetCustomerActivities(ctx context.Context, since, until *time.Time) ([]report.CustomerActivity, error)
+	GetSales(ctx context.Context, from, to time.Time) ([]report.SalesItem, error)
+	GetTaxSummary(ctx context.Context, from, to time.Time) ([]report.TaxSummary, error)
+}
+
+func (s *Service) GetRFMReport(ctx context.Context, filter report.RFMFilter) ([]report.RFMScore, error) {
+	asOf := filter.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	activities, err := s.store.GetCustomerActivities(ctx, filter.Since, &asOf)
+	if err != nil {
+		return []report.RFMScore{}, err
+	}
+
+	res := make([]report.RFMScore, 0, len(activities))
+	for _, a := range activities {
+		score := s.scoreCustomer(a, asOf)
+		if filter.Segment != "" && score.Segment != filter.Segment {
+			continue
+		}
+		res = append(res, score)
+	}
+	return res, nil
+}
+
+func (s *Service) GetSalesReport(ctx context.Context, filter report.SalesFilter) ([]report.SalesItem, error) {
+	res, err := s.store.GetSales(ctx, filter.From, filter.To)
+	if err != nil {
+		return []report.SalesItem{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) GetTaxSummary(ctx context.Context, filter report.TaxFilter) ([]report.TaxSummary, error) {
+	res, err := s.store.GetTaxSummary(ctx, filter.From, filter.To)
+	if err != nil {
+		return []report.TaxSummary{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) scoreCustomer(a report.CustomerActivity, asOf time.Time) report.RFMScore {
+	recencyDays := int(asOf.Sub(a.LastTransaction).Hours() / 24)
+
+	score := report.RFMScore{
+		CustomerActivity: a,
+		RecencyDays:      recencyDays,
+		RecencyScore:     1,
+		FrequencyScore:   1,
+		MonetaryScore:    1,
+	}
+	for _, days := range s.rfmCfg.RecencyDays {
+		if recencyDays <= days {
+			score.RecencyScore++
+		}
+	}
+	for _, count := range s.rfmCfg.Frequency {
+		if a.Frequency >= int64(count) {
+			score.FrequencyScore++
+		}
+	}
+	for _, amount := range s.rfmCfg.Monetary {
+		if a.Monetary >= amount {
+			score.MonetaryScore++
+		}
+	}
+	score.Segment = s.assignSegment(score)
+	return score
+}
+
+func (s *Service) assignSegment(score report.RFMScore) string {
+	for _, seg := range s.rfmCfg.Segments {
+		if inRange(score.RecencyScore, seg.MinRecency, seg.MaxRecency) &&
+			inRange(score.FrequencyScore, seg.MinFrequency, seg.MaxFrequency) &&
+			inRange(score.MonetaryScore, seg.MinMonetary, seg.MaxMonetary) {
+			return seg.Name
+		}
+	}
+	return report.SegmentOthers
+}
+
+func inRange(v, min, max int) bool {
+	if min != 0 && v < min {
+		return false
+	}
+	if max != 0 && v > max {
+		return false
+	}
+	return true
+}
+
+func NewService(store Store, rfmCfg config.RFMConfig) *Service {
+	return &Service{
+		store:  store,
+		rfmCfg: rfmCfg,
+	}
+}
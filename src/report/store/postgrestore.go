@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/corneliusdavid97/laundry-go/src/report"
+)
+
+// aggregated in a single pass over transaction_main, served by the (customer_id, transaction_time) index
+const queryGetCustomerActivities = `
+	select
+		c.id,
+		c.name,
+		coalesce(c.phone,''),
+		max(t.transaction_time),
+		count(t.id),
+		coalesce(sum(t.grand_total),0)
+	from
+		transaction_main t
+		join cust_data c on c.id = t.customer_id
+	where
+		c.anonymized_at is null
+		and t.status <> 'cancelled'
+		and ($1::timestamptz is null or t.transaction_time >= $1)
+		and t.transaction_time <= $2
+	group by
+		c.id, c.name, c.phone
+`
+
+// product lines are counted without their add-ons, add-ons are listed per product they were sold with.
+// lines are grouped by product id under the current product name so renamed products stay together,
+// lines without a product id fall back to their own name
+const queryGetSales = `
+	select
+		'product',
+		d.product_id,
+		coalesce(p.product_name, d.product_name),
+		'',
+		count(d.id),
+		coalesce(sum(d.quantity),0),
+		coalesce(sum(d.price * d.quantity),0)
+	from
+		transaction_detail d
+		join transaction_main t on t.id = d.transaction_id
+		left join product_data p on p.id = d.product_id
+	where
+		t.transaction_time >= $1 and t.transaction_time < $2
+		and t.status <> 'cancelled'
+	group by
+		d.product_id, coalesce(p.product_name, d.product_name)
+	union all
+	select
+		'addon',
+		d.product_id,
+		coalesce(p.product_name, d.product_name),
+		a.addon_name,
+		count(a.id),
+		coalesce(sum(d.quantity),0),
+		coalesce(sum(a.subtotal),0)
+	from
+		transaction_detail_addon a
+		join transaction_detail d on d.id = a.transaction_detail_id
+		join transaction_main t on t.id = d.transaction_id
+		left join product_data p on p.id = d.product_id
+	where
+		t.transaction_time >= $1 and t.transaction_time < $2
+		and t.status <> 'cancelled'
+	group by
+		d.product_id, coalesce(p.product_name, d.product_name), a.addon_name
+	order by
+		3, 1 desc, 4
+`
+
+const queryGetTaxSummary = `
+	select
+		date_trunc('month', t.transaction_time),
+		t.outlet_code,
+		x.name,
+		x.kind,
+		x.rate,
+		x.inclusive,
+		count(distinct t.id),
+		sum(x.base),
+		sum(x.amount)
+	from
+		transaction_tax x
+		join transaction_main t on t.id = x.transaction_id
+	where
+		t.transaction_time >= $1 and t.transaction_time < $2
+		and t.status <> 'cancelled'
+	group by
+		1, 2, 3, 4, 5, 6
+	order by
+		1, 2, 3
+`
+
+type Store struct {
+	getDB func(dbName, replication string) (*sqlx.DB, error)
+}
+
+func (s *Store) GetCustomerActivities(ctx context.Context, since, until *time.Time) ([]report.CustomerActivity, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []report.CustomerActivity{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetCustomerActivities, since, until)
+	if err != nil {
+		return []report.CustomerActivity{}, err
+	}
+	defer rows.Close()
+
+	res := make([]report.CustomerActivity, 0)
+	for rows.Next() {
+		var a report.CustomerActivity
+		err = rows.Scan(&a.CustomerID, &a.CustomerName, &a.PhoneNumber, &a.LastTransaction, &a.Frequency, &a.Monetary)
+		if err != nil {
+			return []report.CustomerActivity{}, err
+		}
+		res = append(res, a)
+	}
+	if err = rows.Err(); err != nil {
+		return []report.CustomerActivity{}, err
+	}
+	return res, nil
+}
+
+func (s *Store) GetSales(ctx context.Context, from, to time.Time) ([]report.SalesItem, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []report.SalesItem{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetSales, from, to)
+	if err != nil {
+		return []report.SalesItem{}, err
+	}
+	defer rows.Close()
+
+	res := make([]report.SalesItem, 0)
+	for rows.Next() {
+		var item report.SalesItem
+		err = rows.Scan(&item.Type, &item.ProductID, &item.ProductName, &item.AddonName, &item.Lines, &item.Quantity, &item.Revenue)
+		if err != nil {
+			return []report.SalesItem{}, err
+		}
+		res = append(res, item)
+	}
+	if err = rows.Err(); err != nil {
+		return []report.SalesItem{}, err
+	}
+	return res, nil
+}
+
+func (s *Store) GetTaxSummary(ctx context.Context, from, to time.Time) ([]report.TaxSummary, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []report.TaxSummary{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetTaxSummary, from, to)
+	if err != nil {
+		return []report.TaxSummary{}, err
+	}
+	defer rows.Close()
+
+	res := make([]report.TaxSummary, 0)
+	for rows.Next() {
+		var t report.TaxSummary
+		err = rows.Scan(&t.Month, &t.OutletCode, &t.Name, &t.Kind, &t.Rate, &t.Inclusive, &t.Transactions, &t.Base, &t.Amount)
+		if err != nil {
+			return []report.TaxSummary{}, err
+		}
+		res = append(res, t)
+	}
+	if err = rows.Err(); err != nil {
+		return []report.TaxSummary{}, err
+	}
+	return res, nil
+}
+
+func NewStore(getDB func(dbName, replication string) (*sqlx.DB, error)) *Store {
+	return &Store{
+		getDB: getDB,
+	}
+}
@@ -2,14 +2,20 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
 	"github.com/corneliusdavid97/laundry-go/src/transaction"
+	"github.com/corneliusdavid97/laundry-go/src/user"
 	"github.com/corneliusdavid97/laundry-go/tools/httputil"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/querybuilder"
 	"github.com/corneliusdavid97/laundry-go/tools/timer"
 )
 
@@ -22,9 +28,39 @@ type Config struct {
 	Timeout time.Duration
 }
 
+// NewTransactionParam is a new order, its due date is computed by the server like the quote's
 type NewTransactionParam struct {
 	transaction.Transaction
-	DueDateStr string `json:"due_date_str"`
+	CreditOverride *Credentials `json:"credit_override"`
+}
+
+type PickupParam struct {
+	ID               int64       `json:"id"`
+	HandedOverBy     string      `json:"handed_over_by"`
+	Payment          money.Money `json:"payment"`
+	PaymentMethod    string      `json:"payment_method"`
+	PaymentReference string      `json:"payment_reference"`
+}
+
+type AddPaymentParam struct {
+	ID          int64       `json:"id"`
+	Amount      money.Money `json:"amount"`
+	Method      string      `json:"method"`
+	Reference   string      `json:"reference"`
+	CashierName string      `json:"cashier_name"`
+}
+
+type StatusChangeParam struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	Actor  string `json:"actor"`
+	Note   string `json:"note"`
+}
+
+// Credentials of an admin approving an order beyond the customer credit limit
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 func (h *HTTPHandler) GetTransactionDataByID(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +78,84 @@ func (h *HTTPHandler) GetTransactionDataByID(w http.ResponseWriter, r *http.Requ
 	var respErrs []httputil.ErrorResponse
 
 	r.ParseForm()
-	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	var res transaction.Transaction
+	var err error
+	if invoiceNumber := r.FormValue("invoice_number"); len(invoiceNumber) > 0 {
+		res, err = h.svc.GetTransactionByInvoiceNumber(ctx, invoiceNumber)
+	} else {
+		var id int64
+		id, err = strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusBadRequest,
+				Title:      http.StatusText(http.StatusBadRequest),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+		res, err = h.svc.GetTransactionDataByID(ctx, id)
+	}
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+	}
+
+	if len(respErrs) > 0 {
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	resp := httputil.Response{
+		Data: parseTransactionResponse(res),
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleNewTransaction(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request NewTransactionParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
 			HttpStatus: http.StatusBadRequest,
@@ -52,7 +165,34 @@ func (h *HTTPHandler) GetTransactionDataByID(w http.ResponseWriter, r *http.Requ
 		httputil.WriteErrorResponse(w, respErrs)
 		return
 	}
-	res, err := h.svc.GetTransactionDataByID(ctx, id)
+
+	parsedReq := parseNewTransactionRequest(request)
+
+	if request.CreditOverride != nil {
+		admin, err := user.GetService().AuthUser(ctx, request.CreditOverride.Username, request.CreditOverride.Password)
+		if err == nil && admin.Role.RoleID != user.RoleAdmin {
+			err = user.ErrForbidden
+		}
+		if err != nil {
+			respErrs = append(respErrs, httputil.ErrorResponse{
+				HttpStatus: http.StatusForbidden,
+				Title:      http.StatusText(http.StatusForbidden),
+				Detail:     err.Error(),
+			})
+			httputil.WriteErrorResponse(w, respErrs)
+			return
+		}
+		parsedReq.CreditApprovedBy = admin.Username
+	}
+
+	saved, err := h.svc.NewTransaction(ctx, parsedReq)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.GetTransactionDataByID(ctx, saved.ID)
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
 			HttpStatus: http.StatusInternalServerError,
@@ -89,7 +229,8 @@ func (h *HTTPHandler) GetTransactionDataByID(w http.ResponseWriter, r *http.Requ
 	httputil.WriteResponse(w, respJson)
 }
 
-func (h *HTTPHandler) HandleNewTransaction(w http.ResponseWriter, r *http.Request) {
+// HandleQuote prices a /transaction/new payload without saving it
+func (h *HTTPHandler) HandleQuote(w http.ResponseWriter, r *http.Request) {
 	t := timer.NewTimer()
 
 	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
@@ -127,42 +268,233 @@ func (h *HTTPHandler) HandleNewTransaction(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	parsedReq, err := parseNewTransactionRequest(request)
+	quote, err := h.svc.Quote(ctx, request.Transaction)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	quote.DueDateStr = quote.DueDate.Format("2006-01-02")
+
+	resp := httputil.Response{
+		Data: quote,
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func (h *HTTPHandler) HandleMarkReady(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
-			HttpStatus: http.StatusInternalServerError,
-			Title:      http.StatusText(http.StatusInternalServerError),
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
 			Detail:     err.Error(),
 		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.ChangeStatus(ctx, transaction.StatusChange{
+		TransactionID: id,
+		Status:        transaction.StatusReady,
+		Actor:         r.FormValue("actor"),
+	})
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeTransactionResponse(w, res, t)
+}
+
+// HandleChangeStatus moves an order along the status workflow, changing an order to the status it
+// already has returns the order without recording anything
+func (h *HTTPHandler) HandleChangeStatus(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
 	}
 
-	err = h.svc.NewTransaction(ctx, parsedReq)
+	var respErrs []httputil.ErrorResponse
+
+	var request StatusChangeParam
+
+	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
-			HttpStatus: http.StatusInternalServerError,
-			Title:      http.StatusText(http.StatusInternalServerError),
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
 			Detail:     err.Error(),
 		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
 	}
 
-	res, err := h.svc.GetTransactionDataByID(ctx, request.ID)
+	err = json.Unmarshal(data, &request)
 	if err != nil {
 		respErrs = append(respErrs, httputil.ErrorResponse{
-			HttpStatus: http.StatusInternalServerError,
-			Title:      http.StatusText(http.StatusInternalServerError),
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
 			Detail:     err.Error(),
 		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
 	}
 
-	if len(respErrs) > 0 {
+	res, err := h.svc.ChangeStatus(ctx, transaction.StatusChange{
+		TransactionID: request.ID,
+		Status:        transaction.Status(request.Status),
+		Actor:         request.Actor,
+		Note:          request.Note,
+	})
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
 		httputil.WriteErrorResponse(w, respErrs)
 		return
 	}
 
+	writeTransactionResponse(w, res, t)
+}
+
+func (h *HTTPHandler) HandleGetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.GetStatusHistory(ctx, id)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	for i := range res {
+		res[i].ChangedAtStr = res[i].ChangedAt.Format("2006-01-02 15:04:05")
+	}
+
 	resp := httputil.Response{
-		Data: parseTransactionResponse(res),
+		Data: res,
 		Meta: &httputil.Meta{
-			DataCount:   1,
+			DataCount:   len(res),
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+// HandleGetTransactions lists orders from the newest, status takes a comma separated list such as
+// washing,drying
+func (h *HTTPHandler) HandleGetTransactions(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodGet, httputil.ContentTypeForm)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	r.ParseForm()
+	filter, err := parseFilter(querybuilder.NewParams(r.Form))
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.GetTransactions(ctx, filter)
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+	for i := range res {
+		res[i] = parseTransactionResponse(res[i])
+	}
+
+	resp := httputil.Response{
+		Data: res,
+		Meta: &httputil.Meta{
+			DataCount:   len(res),
 			ProcessTime: t.GetElapsedTime().Seconds(),
 		},
 	}
@@ -182,29 +514,223 @@ func (h *HTTPHandler) HandleNewTransaction(w http.ResponseWriter, r *http.Reques
 	httputil.WriteResponse(w, respJson)
 }
 
-func parseNewTransactionRequest(param NewTransactionParam) (transaction.Transaction, error) {
-	dueDate, err := time.Parse("2006-01-02 15:04:05", param.DueDateStr)
+// HandlePickup hands an order over to the customer, calling it again for a taken order returns
+// the order without recording anything
+func (h *HTTPHandler) HandlePickup(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request PickupParam
+
+	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return transaction.Transaction{}, err
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
 	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.PickUp(ctx, transaction.Pickup{
+		TransactionID:    request.ID,
+		HandedOverBy:     request.HandedOverBy,
+		Payment:          request.Payment,
+		PaymentMethod:    transaction.PaymentMethod(request.PaymentMethod),
+		PaymentReference: request.PaymentReference,
+	})
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeTransactionResponse(w, res, t)
+}
+
+// HandleAddPayment records a later payment of an order, such as the rest of a down payment
+func (h *HTTPHandler) HandleAddPayment(w http.ResponseWriter, r *http.Request) {
+	t := timer.NewTimer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+	defer cancel()
+
+	httpErr := httputil.ValidateRequest(r, http.MethodPost, httputil.ContentTypeJson)
+	if !httpErr.Empty() {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{httpErr})
+		return
+	}
+
+	var respErrs []httputil.ErrorResponse
+
+	var request AddPaymentParam
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		respErrs = append(respErrs, httputil.ErrorResponse{
+			HttpStatus: http.StatusBadRequest,
+			Title:      http.StatusText(http.StatusBadRequest),
+			Detail:     err.Error(),
+		})
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	res, err := h.svc.AddPayment(ctx, transaction.Payment{
+		TransactionID: request.ID,
+		Amount:        request.Amount,
+		Method:        transaction.PaymentMethod(request.Method),
+		Reference:     request.Reference,
+		CashierName:   request.CashierName,
+	})
+	if err != nil {
+		respErrs = append(respErrs, parseError(err))
+		httputil.WriteErrorResponse(w, respErrs)
+		return
+	}
+
+	writeTransactionResponse(w, res, t)
+}
+
+func writeTransactionResponse(w http.ResponseWriter, trans transaction.Transaction, t *timer.Timer) {
+	resp := httputil.Response{
+		Data: parseTransactionResponse(trans),
+		Meta: &httputil.Meta{
+			DataCount:   1,
+			ProcessTime: t.GetElapsedTime().Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	httputil.WriteResponse(w, respJson)
+}
+
+func parseError(err error) httputil.ErrorResponse {
+	status := http.StatusInternalServerError
+	switch err {
+	case sql.ErrNoRows:
+		status = http.StatusNotFound
+	case transaction.ErrCustomerBlacklisted:
+		status = http.StatusForbidden
+	case transaction.ErrCreditLimitExceeded:
+		status = http.StatusConflict
+	case transaction.ErrEmptyTransaction, transaction.ErrUnknownProduct, transaction.ErrInvalidProductType, transaction.ErrInvalidAddon, transaction.ErrUnknownOutlet,
+		transaction.ErrInvalidQuantity, transaction.ErrQuantityRule, transaction.ErrPriceMismatch, promotion.ErrInvalidCoupon, promotion.ErrMinSpendNotMet:
+		status = http.StatusBadRequest
+	case transaction.ErrInvalidPayment, transaction.ErrInvalidPickup, transaction.ErrInvalidStatus, transaction.ErrMissingActor,
+		transaction.ErrInvalidPaymentMethod, transaction.ErrInvalidPaymentAmount, transaction.ErrMissingCashier, transaction.ErrInvalidPaymentStatus,
+		transaction.ErrInvalidDateRange:
+		status = http.StatusBadRequest
+	case promotion.ErrCouponUsedUp, transaction.ErrNotReady, transaction.ErrUnpaidBalance, transaction.ErrInvalidStatusChange, transaction.ErrOverpayment:
+		status = http.StatusConflict
+	}
+	return httputil.ErrorResponse{
+		HttpStatus: httputil.HttpStatus(status),
+		Title:      http.StatusText(status),
+		Detail:     err.Error(),
+	}
+}
+
+func parseFilter(params *querybuilder.Params) (transaction.Filter, error) {
+	var filter transaction.Filter
+	if s := params.String("status"); s != "" {
+		for _, st := range strings.Split(s, ",") {
+			filter.Statuses = append(filter.Statuses, transaction.Status(strings.TrimSpace(st)))
+		}
+	}
+	filter.CustomerID = params.Int64("customer_id")
+	if unpaidOnly := params.Bool("unpaid_only"); unpaidOnly != nil {
+		filter.UnpaidOnly = *unpaidOnly
+	}
+	if overdueOnly := params.Bool("overdue_only"); overdueOnly != nil {
+		filter.OverdueOnly = *overdueOnly
+	}
+	filter.DateFrom = params.Date("date_from")
+	filter.DateTo = params.Date("date_to")
+	filter.DueFrom = params.Date("due_from")
+	filter.DueTo = params.Date("due_to")
+	filter.CashierName = params.String("cashier_name")
+	filter.PaymentMethod = transaction.PaymentMethod(params.String("payment_method"))
+	filter.Search = params.String("q")
+	filter.Sort, filter.SortDesc = params.Sort("sort", transaction.SortTransactionTime, transaction.SortDueDate, transaction.SortGrandTotal, transaction.SortInvoiceNumber)
+	filter.PaymentStatus = transaction.PaymentStatus(params.OneOf("payment_status", string(transaction.PaymentStatusUnpaid), string(transaction.PaymentStatusPartiallyPaid), string(transaction.PaymentStatusPaid)))
+	filter.Limit = params.Int("limit")
+	filter.Offset = params.Int("offset")
+	return filter, params.Err()
+}
+
+func parseNewTransactionRequest(param NewTransactionParam) transaction.Transaction {
 	return transaction.Transaction{
-		ID:            param.ID,
 		CustomerID:    param.CustomerID,
 		CashierName:   param.CashierName,
+		OutletCode:    param.OutletCode,
+		Subtotal:      param.Subtotal,
+		Discount:      param.Discount,
+		Tax:           param.Tax,
+		Charge:        param.Charge,
 		GrandTotal:    param.GrandTotal,
+		CouponCode:    param.CouponCode,
 		Paid:          param.Paid,
 		PaymentMethod: param.PaymentMethod,
-		DueDate:       &dueDate,
 		Details:       param.Details,
-	}, nil
+		Payments:      param.Payments,
+	}
 }
 
 func parseTransactionResponse(trans transaction.Transaction) transaction.Transaction {
-	var dueDateStr, dateTakenStr, transactionTimeStr string
+	var dueDateStr, dateReadyStr, dateTakenStr, transactionTimeStr string
 	if trans.DueDate != nil {
 		dueDateStr = trans.DueDate.Format("2006-01-02")
 		trans.DueDateStr = &dueDateStr
 	}
+	if trans.DateReady != nil {
+		dateReadyStr = trans.DateReady.Format("2006-01-02 15:04:05")
+		trans.DateReadyStr = &dateReadyStr
+	}
 	if trans.DateTaken != nil {
 		dateTakenStr = trans.DateTaken.Format("2006-01-02 15:04:05")
 		trans.DateTakenStr = &dateTakenStr
@@ -213,6 +739,9 @@ func parseTransactionResponse(trans transaction.Transaction) transaction.Transac
 		trans.TransactionTimeStr = &transactionTimeStr
 		transactionTimeStr = trans.TransactionTime.Format("2006-01-02 15:04:05")
 	}
+	for i := range trans.Payments {
+		trans.Payments[i].PaidAtStr = trans.Payments[i].PaidAt.Format("2006-01-02 15:04:05")
+	}
 	return trans
 }
 
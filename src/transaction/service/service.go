@@ -2,18 +2,44 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/corneliusdavid97/laundry-go/src/config"
+	"github.com/corneliusdavid97/laundry-go/src/customer"
+	"github.com/corneliusdavid97/laundry-go/src/product"
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
 	"github.com/corneliusdavid97/laundry-go/src/transaction"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
 )
 
+// invoicePrefix starts every transaction invoice number
+const invoicePrefix = "LDR"
+
 type Service struct {
 	store Store
+	cfg   Config
+}
+
+type Config struct {
+	Credit     config.CreditConfig
+	Turnaround config.TurnaroundConfig
+	Outlet     config.OutletConfig
+	Tax        config.TaxConfig
+	Rounding   config.RoundingConfig
 }
 
 type Store interface {
-	NewTransaction(ctx context.Context, trans transaction.Transaction) error
-	MarkDateTaken(ctx context.Context, ID int64) error
+	NewTransaction(ctx context.Context, trans transaction.Transaction, numberPrefix string, creditLimit *money.Money) (transaction.Transaction, error)
+	ChangeStatus(ctx context.Context, from transaction.Status, change transaction.StatusChange) (bool, error)
+	MarkDateTaken(ctx context.Context, pickup transaction.Pickup) (bool, error)
+	AddPayment(ctx context.Context, payment transaction.Payment) error
+	GetStatusHistory(ctx context.Context, ID int64) ([]transaction.StatusHistory, error)
+	GetTransactions(ctx context.Context, filter transaction.Filter) ([]transaction.Transaction, error)
 	GetTransactionDataByID(ctx context.Context, ID int64) (transaction.Transaction, error)
+	GetTransactionIDByInvoiceNumber(ctx context.Context, invoiceNumber string) (int64, error)
+	GetTransactionsByCustomerID(ctx context.Context, customerID int64) ([]transaction.Transaction, error)
 }
 
 func (s *Service) GetTransactionDataByID(ctx context.Context, ID int64) (transaction.Transaction, error) {
@@ -24,24 +50,555 @@ func (s *Service) GetTransactionDataByID(ctx context.Context, ID int64) (transac
 	return res, nil
 }
 
-func (s *Service) MarkDateTaken(ctx context.Context, ID int64) error {
-	err := s.store.MarkDateTaken(ctx, ID)
+func (s *Service) GetTransactionByInvoiceNumber(ctx context.Context, invoiceNumber string) (transaction.Transaction, error) {
+	ID, err := s.store.GetTransactionIDByInvoiceNumber(ctx, strings.ToUpper(strings.TrimSpace(invoiceNumber)))
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return s.GetTransactionDataByID(ctx, ID)
+}
+
+func (s *Service) GetTransactionsByCustomerID(ctx context.Context, customerID int64) ([]transaction.Transaction, error) {
+	res, err := s.store.GetTransactionsByCustomerID(ctx, customerID)
 	if err != nil {
-		return err
+		return []transaction.Transaction{}, err
+	}
+	return res, nil
+}
+
+func (s *Service) GetTransactions(ctx context.Context, filter transaction.Filter) ([]transaction.Transaction, error) {
+	for _, st := range filter.Statuses {
+		if !st.Valid() {
+			return []transaction.Transaction{}, transaction.ErrInvalidStatus
+		}
+	}
+	if filter.PaymentStatus != "" && !filter.PaymentStatus.Valid() {
+		return []transaction.Transaction{}, transaction.ErrInvalidPaymentStatus
+	}
+	if filter.PaymentMethod != "" && !filter.PaymentMethod.Valid() {
+		return []transaction.Transaction{}, transaction.ErrInvalidPaymentMethod
+	}
+	if invalidRange(filter.DateFrom, filter.DateTo) || invalidRange(filter.DueFrom, filter.DueTo) {
+		return []transaction.Transaction{}, transaction.ErrInvalidDateRange
 	}
-	return nil
+	return s.store.GetTransactions(ctx, filter)
 }
 
-func (s *Service) NewTransaction(ctx context.Context, trans transaction.Transaction) error {
-	err := s.store.NewTransaction(ctx, trans)
+func invalidRange(from, to *time.Time) bool {
+	return from != nil && to != nil && to.Before(*from)
+}
+
+func (s *Service) GetStatusHistory(ctx context.Context, ID int64) ([]transaction.StatusHistory, error) {
+	_, err := s.store.GetTransactionDataByID(ctx, ID)
 	if err != nil {
-		return err
+		return []transaction.StatusHistory{}, err
+	}
+	return s.store.GetStatusHistory(ctx, ID)
+}
+
+// ChangeStatus moves the order to the requested status when the workflow allows it. Picking up
+// goes through PickUp so the unpaid balance is still checked.
+func (s *Service) ChangeStatus(ctx context.Context, change transaction.StatusChange) (transaction.Transaction, error) {
+	change.Actor = strings.TrimSpace(change.Actor)
+	change.Note = strings.TrimSpace(change.Note)
+	if !change.Status.Valid() {
+		return transaction.Transaction{}, transaction.ErrInvalidStatus
+	}
+	if change.Actor == "" {
+		return transaction.Transaction{}, transaction.ErrMissingActor
+	}
+	if change.Status == transaction.StatusPickedUp {
+		return s.PickUp(ctx, transaction.Pickup{
+			TransactionID: change.TransactionID,
+			HandedOverBy:  change.Actor,
+		})
+	}
+
+	trans, err := s.store.GetTransactionDataByID(ctx, change.TransactionID)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	if trans.Status == change.Status {
+		return trans, nil
+	}
+	if !trans.Status.CanChangeTo(change.Status) {
+		return transaction.Transaction{}, transaction.ErrInvalidStatusChange
+	}
+
+	changed, err := s.store.ChangeStatus(ctx, trans.Status, change)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	trans, err = s.store.GetTransactionDataByID(ctx, change.TransactionID)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	// the order changed status concurrently, the same change made twice is still a no-op
+	if !changed && trans.Status != change.Status {
+		return transaction.Transaction{}, transaction.ErrInvalidStatusChange
+	}
+	return trans, nil
+}
+
+// PickUp hands a ready order over. The unpaid balance must be settled by the pickup payment,
+// except for pay later and corporate customers whose balance is collected later.
+func (s *Service) PickUp(ctx context.Context, pickup transaction.Pickup) (transaction.Transaction, error) {
+	pickup.HandedOverBy = strings.TrimSpace(pickup.HandedOverBy)
+	if pickup.HandedOverBy == "" {
+		return transaction.Transaction{}, transaction.ErrInvalidPickup
+	}
+
+	trans, err := s.store.GetTransactionDataByID(ctx, pickup.TransactionID)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	if trans.Status == transaction.StatusPickedUp {
+		return trans, nil
+	}
+	if trans.Status != transaction.StatusReady {
+		return transaction.Transaction{}, transaction.ErrNotReady
+	}
+
+	unpaid := trans.GrandTotal - trans.Paid
+	if pickup.Payment != 0 && pickup.Payment != unpaid {
+		return transaction.Transaction{}, transaction.ErrInvalidPayment
+	}
+	if pickup.Payment > 0 && pickup.PaymentMethod == "" {
+		pickup.PaymentMethod = trans.PaymentMethod
+	}
+	if pickup.Payment > 0 && !pickup.PaymentMethod.Valid() {
+		return transaction.Transaction{}, transaction.ErrInvalidPaymentMethod
+	}
+	pickup.PaymentReference = strings.TrimSpace(pickup.PaymentReference)
+	if unpaid-pickup.Payment > 0 {
+		cust, err := customer.GetService().GetCustomerByID(ctx, trans.CustomerID)
+		if err != nil {
+			return transaction.Transaction{}, err
+		}
+		if !cust.PayLater && !cust.IsCorporate {
+			return transaction.Transaction{}, transaction.ErrUnpaidBalance
+		}
+	}
+
+	// a concurrent pickup of the same order makes this one a no-op, both return the taken order
+	_, err = s.store.MarkDateTaken(ctx, pickup)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return s.store.GetTransactionDataByID(ctx, pickup.TransactionID)
+}
+
+func (s *Service) AddPayment(ctx context.Context, payment transaction.Payment) (transaction.Transaction, error) {
+	payment.CashierName = strings.TrimSpace(payment.CashierName)
+	payment.Reference = strings.TrimSpace(payment.Reference)
+	if payment.Amount <= 0 {
+		return transaction.Transaction{}, transaction.ErrInvalidPaymentAmount
+	}
+	if !payment.Method.Valid() {
+		return transaction.Transaction{}, transaction.ErrInvalidPaymentMethod
+	}
+	if payment.CashierName == "" {
+		return transaction.Transaction{}, transaction.ErrMissingCashier
+	}
+
+	err := s.store.AddPayment(ctx, payment)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return s.store.GetTransactionDataByID(ctx, payment.TransactionID)
+}
+
+func (s *Service) NewTransaction(ctx context.Context, trans transaction.Transaction) (transaction.Transaction, error) {
+	trans, err := s.priceTransaction(ctx, trans)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	trans, err = preparePayments(trans)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	creditLimit, err := s.creditLimit(ctx, trans)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	trans.Status = transaction.StatusReceived
+	dueDate := s.estimateDueDate(time.Now(), trans.Details)
+	trans.DueDate = &dueDate
+
+	// invoice numbers run per outlet and day, e.g. LDR-MAIN-20261018-0042
+	numberPrefix := fmt.Sprintf("%s-%s-%s", invoicePrefix, trans.OutletCode, time.Now().Format("20060102"))
+	res, err := s.store.NewTransaction(ctx, trans, numberPrefix, creditLimit)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return res, nil
+}
+
+// Quote prices an order the same way NewTransaction does without saving it
+func (s *Service) Quote(ctx context.Context, trans transaction.Transaction) (transaction.Quote, error) {
+	trans, err := s.priceTransaction(ctx, trans)
+	if err != nil {
+		return transaction.Quote{}, err
+	}
+
+	return transaction.Quote{
+		Details:    trans.Details,
+		Discounts:  trans.Discounts,
+		Taxes:      trans.Taxes,
+		OutletCode: trans.OutletCode,
+		Subtotal:   trans.Subtotal,
+		Discount:   trans.Discount,
+		Tax:        trans.Tax,
+		Charge:     trans.Charge,
+		Rounding:   trans.Rounding,
+		GrandTotal: trans.GrandTotal,
+		DueDate:    s.estimateDueDate(time.Now(), trans.Details),
+	}, nil
+}
+
+// estimateDueDate returns the day the slowest line of the order is ready
+func (s *Service) estimateDueDate(from time.Time, details []transaction.TransactionDetail) time.Time {
+	var days int
+	for _, d := range details {
+		lineDays := s.cfg.Turnaround.StandardDays
+		switch d.ProductType {
+		case transaction.ProductTypeExpressToday:
+			lineDays = s.cfg.Turnaround.ExpressTodayDays
+		case transaction.ProductTypeExpressTomorrow:
+			lineDays = s.cfg.Turnaround.ExpressTomorrowDays
+		}
+		if lineDays > days {
+			days = lineDays
+		}
+	}
+	y, m, d := from.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, from.Location())
+}
+
+// priceTransaction fills the line prices, subtotals, discounts, taxes and grand total from the
+// product catalog, promotions and tax rules, amounts sent by the client are only accepted when
+// they agree with the computed ones. Line, discount and tax amounts are rounded to the rupiah as
+// they are computed, only the grand total is rounded to the configured rounding unit.
+func (s *Service) priceTransaction(ctx context.Context, trans transaction.Transaction) (transaction.Transaction, error) {
+	if len(trans.Details) == 0 {
+		return trans, transaction.ErrEmptyTransaction
+	}
+	outletCode, err := s.resolveOutlet(trans.OutletCode)
+	if err != nil {
+		return trans, err
+	}
+
+	active := true
+	filter := product.Filter{Active: &active}
+	if trans.CustomerID != 0 {
+		filter.CustomerID = &trans.CustomerID
+	}
+	products, err := product.GetService().GetAllActiveProducts(ctx, filter)
+	if err != nil {
+		return trans, err
+	}
+	byID := make(map[int64]product.Product, len(products))
+	byName := make(map[string]product.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		byName[strings.ToLower(p.Name)] = p
+	}
+	addons, err := product.GetService().GetAddons(ctx, product.AddonFilter{Active: &active})
+	if err != nil {
+		return trans, err
+	}
+	addonByID := make(map[int64]product.Addon, len(addons))
+	for _, a := range addons {
+		addonByID[a.ID] = a
+	}
+
+	details := make([]transaction.TransactionDetail, len(trans.Details))
+	items := make([]promotion.Item, len(trans.Details))
+	var subtotal money.Money
+	for i, d := range trans.Details {
+		// lines are matched by product id, the name is only used by clients not sending one
+		var p product.Product
+		var ok bool
+		if d.ProductID != nil {
+			p, ok = byID[*d.ProductID]
+		} else {
+			p, ok = byName[strings.ToLower(strings.TrimSpace(d.ProductName))]
+		}
+		if !ok {
+			return trans, transaction.ErrUnknownProduct
+		}
+		if d.Quantity <= 0 {
+			return trans, transaction.ErrInvalidQuantity
+		}
+		if !p.ValidQuantity(d.Quantity) {
+			return trans, transaction.ErrQuantityRule
+		}
+		if d.ProductType == "" {
+			d.ProductType = transaction.ProductTypeStandard
+		}
+		price, err := productPrice(p, d.ProductType)
+		if err != nil {
+			return trans, err
+		}
+		d.Addons, err = priceAddons(p, d, addonByID)
+		if err != nil {
+			return trans, err
+		}
+		lineSubtotal := price.Mul(d.Quantity)
+		for _, a := range d.Addons {
+			lineSubtotal += a.Subtotal
+		}
+		if !matchAmount(d.Price, price) || !matchAmount(d.Subtotal, lineSubtotal) {
+			return trans, transaction.ErrPriceMismatch
+		}
+
+		d.ProductID = &p.ID
+		d.ProductName = p.Name
+		d.Price = price
+		d.Subtotal = lineSubtotal
+		details[i] = d
+		items[i] = promotion.Item{
+			ProductName: p.Name,
+			Price:       price,
+			Quantity:    d.Quantity,
+			Subtotal:    lineSubtotal,
+		}
+		subtotal += lineSubtotal
+	}
+
+	discounts, err := promotion.GetService().Apply(ctx, items, trans.CouponCode, time.Now())
+	if err != nil {
+		return trans, err
+	}
+	discountLines := make([]transaction.DiscountLine, 0, len(discounts))
+	var discount money.Money
+	for _, d := range discounts {
+		discountLines = append(discountLines, transaction.DiscountLine{
+			PromotionID: d.PromotionID,
+			Name:        d.Name,
+			ProductName: d.ProductName,
+			CouponCode:  d.CouponCode,
+			Amount:      d.Amount,
+		})
+		discount += d.Amount
+	}
+
+	taxLines := s.computeTaxes(outletCode, details, subtotal, discount)
+	var tax, charge, exclusive money.Money
+	for _, t := range taxLines {
+		if t.Kind == transaction.TaxKindCharge {
+			charge += t.Amount
+		} else {
+			tax += t.Amount
+		}
+		if !t.Inclusive {
+			exclusive += t.Amount
+		}
+	}
+
+	total := subtotal - discount + exclusive
+	grandTotal := total.Round(s.cfg.Rounding.Unit, money.RoundingMode(s.cfg.Rounding.Mode))
+	if !matchAmount(trans.Subtotal, subtotal) || !matchAmount(trans.Discount, discount) || !matchAmount(trans.Tax, tax) ||
+		!matchAmount(trans.Charge, charge) || !matchAmount(trans.Rounding, grandTotal-total) || !matchAmount(trans.GrandTotal, grandTotal) {
+		return trans, transaction.ErrPriceMismatch
+	}
+
+	trans.OutletCode = outletCode
+	trans.Details = details
+	trans.Discounts = discountLines
+	trans.Taxes = taxLines
+	trans.Subtotal = subtotal
+	trans.Discount = discount
+	trans.Tax = tax
+	trans.Charge = charge
+	trans.Rounding = grandTotal - total
+	trans.GrandTotal = grandTotal
+	return trans, nil
+}
+
+// resolveOutlet checks the outlet code against the configured outlets, an empty code means
+// the first configured outlet
+func (s *Service) resolveOutlet(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		if len(s.cfg.Outlet.Codes) == 0 {
+			return "", nil
+		}
+		return s.cfg.Outlet.Codes[0], nil
+	}
+	for _, c := range s.cfg.Outlet.Codes {
+		if strings.EqualFold(c, code) {
+			return c, nil
+		}
+	}
+	return "", transaction.ErrUnknownOutlet
+}
+
+// computeTaxes applies the tax rules of the outlet, the order discount is spread over the
+// lines in proportion to their subtotal before the rules are computed on them
+func (s *Service) computeTaxes(outletCode string, details []transaction.TransactionDetail, subtotal, discount money.Money) []transaction.TaxLine {
+	res := make([]transaction.TaxLine, 0)
+	for _, rule := range s.cfg.Tax.Rules {
+		if len(rule.Outlets) > 0 && !containsFold(rule.Outlets, outletCode) {
+			continue
+		}
+		var base money.Money
+		for _, d := range details {
+			if len(rule.Products) > 0 && !containsFold(rule.Products, d.ProductName) {
+				continue
+			}
+			base += d.Subtotal.Ratio(subtotal-discount, subtotal)
+		}
+		if base <= 0 || rule.Rate <= 0 {
+			continue
+		}
+
+		amount := base.Percent(rule.Rate)
+		if rule.Inclusive {
+			amount = base.Percent(100 * rule.Rate / (100 + rule.Rate))
+		}
+		kind := transaction.TaxKindTax
+		if rule.Kind == transaction.TaxKindCharge {
+			kind = transaction.TaxKindCharge
+		}
+		res = append(res, transaction.TaxLine{
+			Name:      rule.Name,
+			Kind:      kind,
+			Rate:      rule.Rate,
+			Inclusive: rule.Inclusive,
+			Base:      base,
+			Amount:    amount,
+		})
+	}
+	return res
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// priceAddons prices the add-ons selected on a line, every add-on must belong to the line product
+// and can only be selected once
+func priceAddons(p product.Product, d transaction.TransactionDetail, addonByID map[int64]product.Addon) ([]transaction.DetailAddon, error) {
+	res := make([]transaction.DetailAddon, 0, len(d.Addons))
+	selected := make(map[int64]bool, len(d.Addons))
+	for _, da := range d.Addons {
+		a, ok := addonByID[da.AddonID]
+		if !ok || a.ProductID != p.ID || selected[a.ID] {
+			return []transaction.DetailAddon{}, transaction.ErrInvalidAddon
+		}
+		selected[a.ID] = true
+
+		subtotal := a.Price
+		if a.PriceType == product.AddonPricePerQuantity {
+			subtotal = a.Price.Mul(d.Quantity)
+		}
+		if !matchAmount(da.Price, a.Price) || !matchAmount(da.Subtotal, subtotal) {
+			return []transaction.DetailAddon{}, transaction.ErrPriceMismatch
+		}
+		res = append(res, transaction.DetailAddon{
+			AddonID:   a.ID,
+			Name:      a.Name,
+			PriceType: string(a.PriceType),
+			Price:     a.Price,
+			Subtotal:  subtotal,
+		})
+	}
+	return res, nil
+}
+
+func productPrice(p product.Product, productType transaction.ProductType) (money.Money, error) {
+	switch productType {
+	case transaction.ProductTypeStandard:
+		return p.PriceStandard, nil
+	case transaction.ProductTypeExpressToday:
+		return p.PriceExpressToday, nil
+	case transaction.ProductTypeExpressTomorrow:
+		return p.PriceExpressTomorrow, nil
+	}
+	return 0, transaction.ErrInvalidProductType
+}
+
+// matchAmount reports whether a client amount agrees with the computed one, a zero client
+// amount means the client left it to the server
+func matchAmount(sent, computed money.Money) bool {
+	return sent == 0 || sent == computed
+}
+
+// preparePayments validates the payments made when the order is placed and derives Paid from
+// them, an order sent with only a paid amount is taken as a single payment of its payment method
+func preparePayments(trans transaction.Transaction) (transaction.Transaction, error) {
+	if len(trans.Payments) == 0 && trans.Paid > 0 {
+		trans.Payments = []transaction.Payment{
+			{
+				Amount: trans.Paid,
+				Method: trans.PaymentMethod,
+			},
+		}
+	}
+
+	var paid money.Money
+	for i := range trans.Payments {
+		p := &trans.Payments[i]
+		if p.Amount <= 0 {
+			return trans, transaction.ErrInvalidPaymentAmount
+		}
+		if !p.Method.Valid() {
+			return trans, transaction.ErrInvalidPaymentMethod
+		}
+		p.Reference = strings.TrimSpace(p.Reference)
+		p.CashierName = trans.CashierName
+		paid += p.Amount
+	}
+	if paid > trans.GrandTotal {
+		return trans, transaction.ErrOverpayment
+	}
+	trans.Paid = paid
+	if trans.PaymentMethod == "" && len(trans.Payments) > 0 {
+		trans.PaymentMethod = trans.Payments[0].Method
+	}
+	return trans, nil
+}
+
+// creditLimit rejects an order left partly unpaid when the customer is blacklisted and returns
+// the credit limit its unpaid balance must stay within, nil when the order is paid in full or
+// an admin approval lifts the limit. The store checks the limit while holding a lock on the
+// customer so concurrent orders can't together exceed it.
+func (s *Service) creditLimit(ctx context.Context, trans transaction.Transaction) (*money.Money, error) {
+	unpaid := trans.GrandTotal - trans.Paid
+	if unpaid <= 0 {
+		return nil, nil
+	}
+
+	cust, err := customer.GetService().GetCustomerByID(ctx, trans.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if cust.Blacklisted {
+		return nil, transaction.ErrCustomerBlacklisted
+	}
+	if trans.CreditApprovedBy != "" {
+		return nil, nil
+	}
+
+	limit := s.cfg.Credit.DefaultLimit
+	if cust.PayLater || cust.IsCorporate {
+		limit = cust.CreditLimit
 	}
-	return nil
+	return &limit, nil
 }
 
-func NewService(store Store) *Service {
+func NewService(store Store, cfg Config) *Service {
 	return &Service{
 		store: store,
+		cfg:   cfg,
 	}
 }
@@ -6,39 +6,267 @@ import (
 	"log"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 
+	"github.com/corneliusdavid97/laundry-go/src/promotion"
 	"github.com/corneliusdavid97/laundry-go/src/transaction"
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+	"github.com/corneliusdavid97/laundry-go/tools/querybuilder"
 )
 
 const queryInsertTransactionData = `
 	insert into transaction_main(
-		id,
+		invoice_number,
 		customer_id, 
+		outlet_code,
+		status,
+		subtotal,
+		discount,
+		tax,
+		charge,
+		rounding,
 		grand_total, 
 		paid,
 		due_date,
 		payment_method, 
-		cashier_name
+		cashier_name,
+		credit_approved_by,
+		coupon_code
 	)values(
 		?,
 		?, 
 		?, 
 		?, 
 		?, 
+		?, 
+		?, 
+		?, 
+		?, 
+		?, 
+		?, 
+		?, 
 		?,
-		?
+		?,
+		nullif(?, ''),
+		nullif(?, '')
+	)
+	returning id
+`
+
+// queryNextTransactionSequence hands out the next number of an invoice number prefix, the counter
+// row stays locked until the transaction ends so concurrent transactions of the same outlet and
+// day wait for each other and a rolled back transaction gives its number back
+const queryNextTransactionSequence = `
+	insert into transaction_counter(
+		prefix,
+		last_seq
+	)values(
+		$1,
+		1
 	)
+	on conflict (prefix) do update set
+		last_seq=transaction_counter.last_seq + 1
+	returning last_seq
+`
+
+const queryGetTransactionIDByInvoiceNumber = `
+	select
+		id
+	from
+		transaction_main
+	where
+		invoice_number=$1
+`
+
+const queryInsertTransactionTax = `
+	insert into transaction_tax(
+		transaction_id,
+		name,
+		kind,
+		rate,
+		inclusive,
+		base,
+		amount
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6,
+		$7
+	)
+`
+
+const queryGetTransactionTaxesByTransactionIDs = `
+	select
+		transaction_id,
+		name,
+		kind,
+		rate,
+		inclusive,
+		base,
+		amount
+	from
+		transaction_tax
+	where
+		transaction_id = any($1)
+	order by
+		id
+`
+
+const queryInsertTransactionDiscount = `
+	insert into transaction_discount(
+		transaction_id,
+		promotion_id,
+		name,
+		product_name,
+		amount
+	)values(
+		$1,
+		$2,
+		$3,
+		nullif($4, ''),
+		$5
+	)
+`
+
+// queryUseCoupon counts a coupon use, it only succeeds while the usage limit is not reached
+const queryUseCoupon = `
+	update promotion_data set
+		usage_count=usage_count+1
+	where
+		id=$1 and (usage_limit=0 or usage_count < usage_limit)
+`
+
+const queryGetTransactionDiscountsByTransactionIDs = `
+	select
+		d.transaction_id,
+		d.id,
+		d.promotion_id,
+		d.name,
+		coalesce(d.product_name,''),
+		coalesce(p.coupon_code,''),
+		d.amount
+	from
+		transaction_discount d
+		join promotion_data p on p.id = d.promotion_id
+	where
+		d.transaction_id = any($1)
+	order by
+		d.id
+`
+
+const queryChangeStatus = `
+	update transaction_main set
+		status=$3,
+		date_ready=case when $3='ready' then now() else date_ready end
+	where
+		id=$1 and status=$2
+`
+
+const queryInsertStatusHistory = `
+	insert into transaction_status_history(
+		transaction_id,
+		from_status,
+		to_status,
+		actor,
+		note
+	)values(
+		$1,
+		nullif($2, ''),
+		$3,
+		$4,
+		nullif($5, '')
+	)
+`
+
+const queryGetStatusHistory = `
+	select
+		id,
+		transaction_id,
+		coalesce(from_status,''),
+		to_status,
+		actor,
+		coalesce(note,''),
+		changed_at
+	from
+		transaction_status_history
+	where
+		transaction_id=$1
+	order by
+		id
 `
 
+// queryMarkDateTaken only applies to ready orders, so a repeated pickup can't record its
+// payment twice
 const queryMarkDateTaken = `
 	update transaction_main set
-		date_taken=now()
-	where id=$1
+		status='picked_up',
+		date_taken=now(),
+		handed_over_by=$2
+	where
+		id=$1 and status='ready'
+`
+
+const queryInsertPayment = `
+	insert into transaction_payment(
+		transaction_id,
+		amount,
+		method,
+		reference,
+		cashier_name
+	)values(
+		$1,
+		$2,
+		$3,
+		nullif($4, ''),
+		$5
+	)
+	returning id, paid_at
+`
+
+// queryRefreshPaid derives the paid amount of a transaction from its payment ledger
+const queryRefreshPaid = `
+	update transaction_main set
+		paid=(select coalesce(sum(amount),0) from transaction_payment where transaction_id=$1)
+	where
+		id=$1
+`
+
+// queryLockUnpaid locks the transaction until the payment is recorded so concurrent payments
+// can't together exceed the grand total
+const queryLockUnpaid = `
+	select
+		grand_total - paid
+	from
+		transaction_main
+	where
+		id=$1
+	for update
+`
+
+const queryGetPaymentsByTransactionIDs = `
+	select
+		id,
+		transaction_id,
+		amount,
+		method,
+		coalesce(reference,''),
+		cashier_name,
+		paid_at
+	from
+		transaction_payment
+	where
+		transaction_id = any($1)
+	order by
+		id
 `
 
 const queryInsertTransactionDetail = `
 	insert into transaction_detail(
 		transaction_id, 
+		product_id, 
 		product_name, 
 		product_type, 
 		price, 
@@ -46,19 +274,69 @@ const queryInsertTransactionDetail = `
 		subtotal
 	)values
 		%s
+	returning id
+`
+
+const queryInsertDetailAddon = `
+	insert into transaction_detail_addon(
+		transaction_detail_id,
+		addon_id,
+		addon_name,
+		price_type,
+		price,
+		subtotal
+	)values(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6
+	)
+`
+
+const queryGetDetailAddonsByTransactionIDs = `
+	select
+		a.transaction_detail_id,
+		a.id,
+		a.addon_id,
+		a.addon_name,
+		a.price_type,
+		a.price,
+		a.subtotal
+	from
+		transaction_detail_addon a
+		join transaction_detail d on d.id = a.transaction_detail_id
+	where
+		d.transaction_id = any($1)
+	order by
+		a.id
 `
 
 const queryGetTransactionDataByID = `
 	select
 		id,
+		coalesce(invoice_number,''),
 		customer_id,
+		outlet_code,
+		status,
+		subtotal,
+		discount,
+		tax,
+		charge,
+		rounding,
 		grand_total,
 		paid,
 		transaction_time,
 		due_date,
+		date_ready,
 		date_taken,
 		payment_method,
-		cashier_name
+		cashier_name,
+		coalesce(handed_over_by,''),
+		invoice_id,
+		coalesce(credit_approved_by,''),
+		coalesce(coupon_code,'')
 	from
 		transaction_main
 	where
@@ -68,6 +346,7 @@ const queryGetTransactionDataByID = `
 const queryGetTransactionDetailsByTransactionID = `
 	select 
 		id,
+		product_id,
 		product_name,
 		product_type,
 		price,
@@ -79,6 +358,107 @@ const queryGetTransactionDetailsByTransactionID = `
 		transaction_id = $1
 `
 
+const queryGetTransactionsByCustomerID = `
+	select
+		id,
+		coalesce(invoice_number,''),
+		customer_id,
+		outlet_code,
+		status,
+		subtotal,
+		discount,
+		tax,
+		charge,
+		rounding,
+		grand_total,
+		paid,
+		transaction_time,
+		due_date,
+		date_ready,
+		date_taken,
+		payment_method,
+		cashier_name,
+		coalesce(handed_over_by,''),
+		invoice_id,
+		coalesce(credit_approved_by,''),
+		coalesce(coupon_code,'')
+	from
+		transaction_main
+	where
+		customer_id=$1
+	order by
+		transaction_time
+`
+
+// queryGetTransactions is completed with the conditions, order and pagination of a filter
+const queryGetTransactions = `
+	select
+		id,
+		coalesce(invoice_number,''),
+		customer_id,
+		outlet_code,
+		status,
+		subtotal,
+		discount,
+		tax,
+		charge,
+		rounding,
+		grand_total,
+		paid,
+		transaction_time,
+		due_date,
+		date_ready,
+		date_taken,
+		payment_method,
+		cashier_name,
+		coalesce(handed_over_by,''),
+		invoice_id,
+		coalesce(credit_approved_by,''),
+		coalesce(coupon_code,'')
+	from
+		transaction_main
+	where
+		%s
+`
+
+const queryGetTransactionDetailsByTransactionIDs = `
+	select 
+		transaction_id,
+		id,
+		product_id,
+		product_name,
+		product_type,
+		price,
+		quantity,
+		subtotal
+	from 
+		transaction_detail
+	where
+		transaction_id = any($1)
+	order by
+		id
+`
+
+// queryLockCustomer serializes the credit checks of concurrent orders of the same customer
+const queryLockCustomer = `
+	select
+		id
+	from
+		cust_data
+	where
+		id=$1
+	for update
+`
+
+const queryGetOutstandingBalance = `
+	select
+		coalesce(sum(grand_total - paid),0)
+	from
+		transaction_main
+	where
+		customer_id=$1 and grand_total > paid and status <> 'cancelled'
+`
+
 type Store struct {
 	getDB func(dbName, replication string) (*sqlx.DB, error)
 }
@@ -95,7 +475,7 @@ func (s *Store) GetTransactionDataByID(ctx context.Context, ID int64) (transacti
 	}
 	row := tx.QueryRowContext(ctx, queryGetTransactionDataByID, ID)
 	var trans transaction.Transaction
-	err = row.Scan(&trans.ID, &trans.CustomerID, &trans.GrandTotal, &trans.Paid, &trans.TransactionTime, &trans.DueDate, &trans.DateTaken, &trans.PaymentMethod, &trans.CashierName)
+	err = row.Scan(&trans.ID, &trans.InvoiceNumber, &trans.CustomerID, &trans.OutletCode, &trans.Status, &trans.Subtotal, &trans.Discount, &trans.Tax, &trans.Charge, &trans.Rounding, &trans.GrandTotal, &trans.Paid, &trans.TransactionTime, &trans.DueDate, &trans.DateReady, &trans.DateTaken, &trans.PaymentMethod, &trans.CashierName, &trans.HandedOverBy, &trans.InvoiceID, &trans.CreditApprovedBy, &trans.CouponCode)
 	if err != nil {
 		tx.Rollback()
 		return transaction.Transaction{}, err
@@ -108,30 +488,499 @@ func (s *Store) GetTransactionDataByID(ctx context.Context, ID int64) (transacti
 
 	for rows.Next() {
 		var detail transaction.TransactionDetail
-		err = rows.Scan(&detail.ID, &detail.ProductName, &detail.ProductType, &detail.Price, &detail.Quantity, &detail.Subtotal)
+		err = rows.Scan(&detail.ID, &detail.ProductID, &detail.ProductName, &detail.ProductType, &detail.Price, &detail.Quantity, &detail.Subtotal)
 		if err != nil {
 			log.Printf("[Transaction][Store] failed to scan row, detail: %v, err:%v\n", detail, err)
 		}
 		trans.Details = append(trans.Details, detail)
 	}
 	tx.Commit()
-	return trans, nil
+
+	res := []transaction.Transaction{trans}
+	err = s.fillDetailAddons(ctx, db, res)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	err = s.fillTransactionDiscounts(ctx, db, res)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	err = s.fillTransactionTaxes(ctx, db, res)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	err = s.fillTransactionPayments(ctx, db, res)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return res[0], nil
 }
 
-func (s *Store) MarkDateTaken(ctx context.Context, ID int64) error {
+func (s *Store) GetTransactionIDByInvoiceNumber(ctx context.Context, invoiceNumber string) (int64, error) {
 	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return 0, err
+	}
+
+	var ID int64
+	err = db.QueryRowContext(ctx, queryGetTransactionIDByInvoiceNumber, invoiceNumber).Scan(&ID)
+	if err != nil {
+		return 0, err
+	}
+	return ID, nil
+}
+
+func (s *Store) GetTransactionsByCustomerID(ctx context.Context, customerID int64) ([]transaction.Transaction, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+
+	return s.getTransactions(ctx, db, queryGetTransactionsByCustomerID, customerID)
+}
+
+func (s *Store) GetTransactions(ctx context.Context, filter transaction.Filter) ([]transaction.Transaction, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+
+	where, args := constructFilter(filter)
+	return s.getTransactions(ctx, db, fmt.Sprintf(queryGetTransactions, where), args...)
+}
+
+// sortColumns maps the transaction sort fields to their columns in queryGetTransactions
+var sortColumns = map[string]string{
+	transaction.SortTransactionTime: "transaction_time",
+	transaction.SortDueDate:         "due_date",
+	transaction.SortGrandTotal:      "grand_total",
+	transaction.SortInvoiceNumber:   "invoice_number",
+}
+
+// constructFilter builds the where clause of queryGetTransactions
+func constructFilter(filter transaction.Filter) (string, []interface{}) {
+	b := querybuilder.New()
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, st := range filter.Statuses {
+			statuses = append(statuses, string(st))
+		}
+		b.Where("status = any(?)", pq.Array(statuses))
+	}
+	if filter.CustomerID != nil {
+		b.Where("customer_id=?", *filter.CustomerID)
+	}
+	switch filter.PaymentStatus {
+	case transaction.PaymentStatusUnpaid:
+		b.Where("paid <= 0 and grand_total > 0")
+	case transaction.PaymentStatusPartiallyPaid:
+		b.Where("paid > 0 and paid < grand_total")
+	case transaction.PaymentStatusPaid:
+		b.Where("paid >= grand_total")
+	}
+	if filter.UnpaidOnly {
+		b.Where("paid < grand_total")
+	}
+	if filter.OverdueOnly {
+		b.Where("due_date < current_date and status not in ('ready', 'picked_up', 'cancelled')")
+	}
+	if filter.DateFrom != nil {
+		b.Where("transaction_time >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		b.Where("transaction_time < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+	if filter.DueFrom != nil {
+		b.Where("due_date >= ?", *filter.DueFrom)
+	}
+	if filter.DueTo != nil {
+		b.Where("due_date < ?", filter.DueTo.AddDate(0, 0, 1))
+	}
+	if filter.CashierName != "" {
+		b.Where("lower(cashier_name)=lower(?)", filter.CashierName)
+	}
+	if filter.PaymentMethod != "" {
+		b.Where("(payment_method=? or exists (select 1 from transaction_payment p where p.transaction_id=transaction_main.id and p.method=?))", filter.PaymentMethod, filter.PaymentMethod)
+	}
+	if filter.Search != "" {
+		pattern := querybuilder.Like(filter.Search)
+		b.Where("(invoice_number ilike ? or customer_id in (select id from cust_data where name ilike ? or phone ilike ?))", pattern, pattern, pattern)
+	}
+
+	column, ok := sortColumns[filter.Sort]
+	desc := filter.SortDesc
+	if !ok {
+		column, desc = sortColumns[transaction.SortTransactionTime], true
+	}
+	return b.OrderBy(column, desc).OrderBy("id", desc).Page(filter.Limit, filter.Offset).Build()
+}
+
+// getTransactions runs a transaction select query and loads the lines of every transaction it returns
+func (s *Store) getTransactions(ctx context.Context, db *sqlx.DB, query string, args ...interface{}) ([]transaction.Transaction, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	defer rows.Close()
+
+	res := make([]transaction.Transaction, 0)
+	for rows.Next() {
+		var trans transaction.Transaction
+		err = rows.Scan(&trans.ID, &trans.InvoiceNumber, &trans.CustomerID, &trans.OutletCode, &trans.Status, &trans.Subtotal, &trans.Discount, &trans.Tax, &trans.Charge, &trans.Rounding, &trans.GrandTotal, &trans.Paid, &trans.TransactionTime, &trans.DueDate, &trans.DateReady, &trans.DateTaken, &trans.PaymentMethod, &trans.CashierName, &trans.HandedOverBy, &trans.InvoiceID, &trans.CreditApprovedBy, &trans.CouponCode)
+		if err != nil {
+			return []transaction.Transaction{}, err
+		}
+		res = append(res, trans)
+	}
+	if err = rows.Err(); err != nil {
+		return []transaction.Transaction{}, err
+	}
+
+	err = s.fillTransactionDetails(ctx, db, res)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	err = s.fillDetailAddons(ctx, db, res)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	err = s.fillTransactionDiscounts(ctx, db, res)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	err = s.fillTransactionTaxes(ctx, db, res)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	err = s.fillTransactionPayments(ctx, db, res)
+	if err != nil {
+		return []transaction.Transaction{}, err
+	}
+	return res, nil
+}
+
+// fillTransactionDetails loads the details of every transaction in trans with a single query
+func (s *Store) fillTransactionDetails(ctx context.Context, db *sqlx.DB, trans []transaction.Transaction) error {
+	if len(trans) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(trans))
+	idx := make(map[int64]int, len(trans))
+	for i, t := range trans {
+		ids = append(ids, t.ID)
+		idx[t.ID] = i
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetTransactionDetailsByTransactionIDs, pq.Array(ids))
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	_, err = db.ExecContext(ctx, queryMarkDateTaken, ID)
+	for rows.Next() {
+		var transID int64
+		var detail transaction.TransactionDetail
+		err = rows.Scan(&transID, &detail.ID, &detail.ProductID, &detail.ProductName, &detail.ProductType, &detail.Price, &detail.Quantity, &detail.Subtotal)
+		if err != nil {
+			log.Printf("[Transaction][Store] failed to scan row, detail: %v, err:%v\n", detail, err)
+			continue
+		}
+		if i, ok := idx[transID]; ok {
+			trans[i].Details = append(trans[i].Details, detail)
+		}
+	}
+	return rows.Err()
+}
+
+// fillDetailAddons loads the add-ons of every detail of the transactions in trans with a single query
+func (s *Store) fillDetailAddons(ctx context.Context, db *sqlx.DB, trans []transaction.Transaction) error {
+	if len(trans) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(trans))
+	details := make(map[int64]*transaction.TransactionDetail)
+	for i := range trans {
+		ids = append(ids, trans[i].ID)
+		for j := range trans[i].Details {
+			d := &trans[i].Details[j]
+			d.Addons = make([]transaction.DetailAddon, 0)
+			details[d.ID] = d
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetDetailAddonsByTransactionIDs, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var detailID int64
+		var a transaction.DetailAddon
+		err = rows.Scan(&detailID, &a.ID, &a.AddonID, &a.Name, &a.PriceType, &a.Price, &a.Subtotal)
+		if err != nil {
+			return err
+		}
+		if d, ok := details[detailID]; ok {
+			d.Addons = append(d.Addons, a)
+		}
+	}
+	return rows.Err()
+}
+
+// fillTransactionDiscounts loads the discount lines of every transaction in trans with a single query
+func (s *Store) fillTransactionDiscounts(ctx context.Context, db *sqlx.DB, trans []transaction.Transaction) error {
+	if len(trans) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(trans))
+	idx := make(map[int64]int, len(trans))
+	for i, t := range trans {
+		ids = append(ids, t.ID)
+		idx[t.ID] = i
+		trans[i].Discounts = make([]transaction.DiscountLine, 0)
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetTransactionDiscountsByTransactionIDs, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transID int64
+		var d transaction.DiscountLine
+		err = rows.Scan(&transID, &d.ID, &d.PromotionID, &d.Name, &d.ProductName, &d.CouponCode, &d.Amount)
+		if err != nil {
+			return err
+		}
+		if i, ok := idx[transID]; ok {
+			trans[i].Discounts = append(trans[i].Discounts, d)
+		}
+	}
+	return rows.Err()
+}
+
+// fillTransactionTaxes loads the tax and charge lines of every transaction in trans with a single query
+func (s *Store) fillTransactionTaxes(ctx context.Context, db *sqlx.DB, trans []transaction.Transaction) error {
+	if len(trans) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(trans))
+	idx := make(map[int64]int, len(trans))
+	for i, t := range trans {
+		ids = append(ids, t.ID)
+		idx[t.ID] = i
+		trans[i].Taxes = make([]transaction.TaxLine, 0)
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetTransactionTaxesByTransactionIDs, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transID int64
+		var t transaction.TaxLine
+		err = rows.Scan(&transID, &t.Name, &t.Kind, &t.Rate, &t.Inclusive, &t.Base, &t.Amount)
+		if err != nil {
+			return err
+		}
+		if i, ok := idx[transID]; ok {
+			trans[i].Taxes = append(trans[i].Taxes, t)
+		}
+	}
+	return rows.Err()
+}
+
+// fillTransactionPayments loads the payment ledger of every transaction in trans with a single query
+func (s *Store) fillTransactionPayments(ctx context.Context, db *sqlx.DB, trans []transaction.Transaction) error {
+	if len(trans) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(trans))
+	idx := make(map[int64]int, len(trans))
+	for i, t := range trans {
+		ids = append(ids, t.ID)
+		idx[t.ID] = i
+		trans[i].Payments = make([]transaction.Payment, 0)
+		trans[i].PaymentStatus = transaction.PaymentStatusOf(t.Paid, t.GrandTotal)
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetPaymentsByTransactionIDs, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p transaction.Payment
+		err = rows.Scan(&p.ID, &p.TransactionID, &p.Amount, &p.Method, &p.Reference, &p.CashierName, &p.PaidAt)
+		if err != nil {
+			return err
+		}
+		if i, ok := idx[p.TransactionID]; ok {
+			trans[i].Payments = append(trans[i].Payments, p)
+		}
+	}
+	return rows.Err()
+}
+
+// checkCreditLimit fails with ErrCreditLimitExceeded when the unpaid balance of trans would take
+// the outstanding balance of its customer over limit, the customer stays locked until tx ends
+func checkCreditLimit(ctx context.Context, tx *sqlx.Tx, trans transaction.Transaction, limit money.Money) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, queryLockCustomer, trans.CustomerID).Scan(&id)
 	if err != nil {
 		return err
 	}
+
+	var outstanding money.Money
+	err = tx.QueryRowContext(ctx, queryGetOutstandingBalance, trans.CustomerID).Scan(&outstanding)
+	if err != nil {
+		return err
+	}
+	if outstanding+trans.GrandTotal-trans.Paid > limit {
+		return transaction.ErrCreditLimitExceeded
+	}
 	return nil
 }
 
-func (s *Store) NewTransaction(ctx context.Context, trans transaction.Transaction) error {
+// ChangeStatus moves the order from status from to status to and records the change, it returns
+// false when the order is no longer in status from
+func (s *Store) ChangeStatus(ctx context.Context, from transaction.Status, change transaction.StatusChange) (bool, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, queryChangeStatus, change.TransactionID, from, change.Status)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertStatusHistory, change.TransactionID, from, change.Status, change.Actor, change.Note)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *Store) GetStatusHistory(ctx context.Context, ID int64) ([]transaction.StatusHistory, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return []transaction.StatusHistory{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, queryGetStatusHistory, ID)
+	if err != nil {
+		return []transaction.StatusHistory{}, err
+	}
+	defer rows.Close()
+
+	res := make([]transaction.StatusHistory, 0)
+	for rows.Next() {
+		var h transaction.StatusHistory
+		err = rows.Scan(&h.ID, &h.TransactionID, &h.FromStatus, &h.ToStatus, &h.Actor, &h.Note, &h.ChangedAt)
+		if err != nil {
+			return []transaction.StatusHistory{}, err
+		}
+		res = append(res, h)
+	}
+	if err = rows.Err(); err != nil {
+		return []transaction.StatusHistory{}, err
+	}
+	return res, nil
+}
+
+// MarkDateTaken records the pickup, its payment and the change to picked up, it returns false
+// when the order was not ready or already taken and fails with ErrOverpayment when the payment
+// exceeds the unpaid balance
+func (s *Store) MarkDateTaken(ctx context.Context, pickup transaction.Pickup) (bool, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, queryMarkDateTaken, pickup.TransactionID, pickup.HandedOverBy)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertStatusHistory, pickup.TransactionID, transaction.StatusReady, transaction.StatusPickedUp, pickup.HandedOverBy, "")
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if pickup.Payment > 0 {
+		// the balance was checked before the update locked the row, a payment recorded since
+		// then may have settled part of it
+		var unpaid money.Money
+		err = tx.QueryRowContext(ctx, queryLockUnpaid, pickup.TransactionID).Scan(&unpaid)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if pickup.Payment > unpaid {
+			tx.Rollback()
+			return false, transaction.ErrOverpayment
+		}
+
+		_, err = tx.ExecContext(ctx, queryInsertPayment, pickup.TransactionID, pickup.Payment, pickup.PaymentMethod, pickup.PaymentReference, pickup.HandedOverBy)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, queryRefreshPaid, pickup.TransactionID)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// AddPayment records a payment in the ledger of its transaction and updates the paid amount, it
+// fails with ErrOverpayment when the payment exceeds the unpaid balance
+func (s *Store) AddPayment(ctx context.Context, payment transaction.Payment) error {
 	db, err := s.getDB("db_main", "master")
 	if err != nil {
 		return err
@@ -142,29 +991,167 @@ func (s *Store) NewTransaction(ctx context.Context, trans transaction.Transactio
 		return err
 	}
 
+	var unpaid money.Money
+	err = tx.QueryRowContext(ctx, queryLockUnpaid, payment.TransactionID).Scan(&unpaid)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if payment.Amount > unpaid {
+		tx.Rollback()
+		return transaction.ErrOverpayment
+	}
+
+	err = tx.QueryRowContext(ctx, queryInsertPayment, payment.TransactionID, payment.Amount, payment.Method, payment.Reference, payment.CashierName).Scan(&payment.ID, &payment.PaidAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryRefreshPaid, payment.TransactionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// NewTransaction inserts the transaction with the next invoice number of numberPrefix and
+// returns it with its assigned ID and invoice number. A non-nil creditLimit is checked against
+// the outstanding balance of the customer within the same database transaction.
+func (s *Store) NewTransaction(ctx context.Context, trans transaction.Transaction, numberPrefix string, creditLimit *money.Money) (transaction.Transaction, error) {
+	db, err := s.getDB("db_main", "master")
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	if creditLimit != nil {
+		err = checkCreditLimit(ctx, tx, trans, *creditLimit)
+		if err != nil {
+			tx.Rollback()
+			return transaction.Transaction{}, err
+		}
+	}
+
+	var seq int64
+	err = tx.QueryRowContext(ctx, queryNextTransactionSequence, numberPrefix).Scan(&seq)
+	if err != nil {
+		tx.Rollback()
+		return transaction.Transaction{}, err
+	}
+	trans.InvoiceNumber = fmt.Sprintf("%s-%04d", numberPrefix, seq)
+
 	// insert main data
 	query := tx.Rebind(queryInsertTransactionData)
-	_, err = tx.ExecContext(ctx, query, trans.ID, trans.CustomerID, trans.GrandTotal, trans.Paid, trans.DueDate, trans.PaymentMethod, trans.CashierName)
+	err = tx.QueryRowContext(ctx, query, trans.InvoiceNumber, trans.CustomerID, trans.OutletCode, trans.Status, trans.Subtotal, trans.Discount, trans.Tax, trans.Charge, trans.Rounding, trans.GrandTotal, trans.Paid, trans.DueDate, trans.PaymentMethod, trans.CashierName, trans.CreditApprovedBy, trans.CouponCode).Scan(&trans.ID)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return transaction.Transaction{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertStatusHistory, trans.ID, "", trans.Status, trans.CashierName, "")
+	if err != nil {
+		tx.Rollback()
+		return transaction.Transaction{}, err
+	}
+
+	// insert payments made when the order was placed
+	for i := range trans.Payments {
+		p := &trans.Payments[i]
+		p.TransactionID = trans.ID
+		err = tx.QueryRowContext(ctx, queryInsertPayment, trans.ID, p.Amount, p.Method, p.Reference, p.CashierName).Scan(&p.ID, &p.PaidAt)
+		if err != nil {
+			tx.Rollback()
+			return transaction.Transaction{}, err
+		}
 	}
 
 	// insert details
 	var params []interface{}
 	for _, v := range trans.Details {
-		params = append(params, trans.ID, v.ProductName, v.ProductType, v.Price, v.Quantity, v.Subtotal)
+		params = append(params, trans.ID, v.ProductID, v.ProductName, v.ProductType, v.Price, v.Quantity, v.Subtotal)
 	}
 
 	query = tx.Rebind(constructQueryInsertTransactionDetail(len(trans.Details)))
-	_, err = tx.ExecContext(ctx, query, params...)
+	rows, err := tx.QueryContext(ctx, query, params...)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return transaction.Transaction{}, err
+	}
+	// rows of a multi row insert are returned in the order of its values
+	detailIDs := make([]int64, 0, len(trans.Details))
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return transaction.Transaction{}, err
+		}
+		detailIDs = append(detailIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return transaction.Transaction{}, err
 	}
 
-	tx.Commit()
-	return nil
+	// insert add-ons of every detail
+	for i, v := range trans.Details {
+		for _, a := range v.Addons {
+			_, err = tx.ExecContext(ctx, queryInsertDetailAddon, detailIDs[i], a.AddonID, a.Name, a.PriceType, a.Price, a.Subtotal)
+			if err != nil {
+				tx.Rollback()
+				return transaction.Transaction{}, err
+			}
+		}
+	}
+
+	// insert discounts, a coupon is only counted as used together with the transaction
+	for _, d := range trans.Discounts {
+		if d.CouponCode != "" {
+			res, err := tx.ExecContext(ctx, queryUseCoupon, d.PromotionID)
+			if err != nil {
+				tx.Rollback()
+				return transaction.Transaction{}, err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				tx.Rollback()
+				return transaction.Transaction{}, err
+			}
+			if affected == 0 {
+				tx.Rollback()
+				return transaction.Transaction{}, promotion.ErrCouponUsedUp
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, queryInsertTransactionDiscount, trans.ID, d.PromotionID, d.Name, d.ProductName, d.Amount)
+		if err != nil {
+			tx.Rollback()
+			return transaction.Transaction{}, err
+		}
+	}
+
+	// insert taxes and charges
+	for _, t := range trans.Taxes {
+		_, err = tx.ExecContext(ctx, queryInsertTransactionTax, trans.ID, t.Name, t.Kind, t.Rate, t.Inclusive, t.Base, t.Amount)
+		if err != nil {
+			tx.Rollback()
+			return transaction.Transaction{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	return trans, nil
 }
 
 func constructQueryInsertTransactionDetail(length int) string {
@@ -173,7 +1160,7 @@ func constructQueryInsertTransactionDetail(length int) string {
 		if i > 0 {
 			values += ","
 		}
-		values += "(?, ?, ?, ?, ?, ?)"
+		values += "(?, ?, ?, ?, ?, ?, ?)"
 	}
 	return fmt.Sprintf(queryInsertTransactionDetail, values)
 }
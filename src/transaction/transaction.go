@@ -2,34 +2,265 @@ package transaction
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
 )
 
 type Transaction struct {
+	// ID is assigned by the database, InvoiceNumber is the human readable number printed on
+	// the receipt, e.g. LDR-MAIN-20261018-0042. Paid is the sum of Payments, PaymentMethod is
+	// the method the order was first paid with.
 	ID                 int64               `json:"id"`
+	InvoiceNumber      string              `json:"invoice_number"`
 	CustomerID         int64               `json:"cust_id"`
-	GrandTotal         float64             `json:"grand_total"`
-	Paid               float64             `json:"paid"`
+	OutletCode         string              `json:"outlet_code"`
+	Status             Status              `json:"status"`
+	Subtotal           money.Money         `json:"subtotal"`
+	Discount           money.Money         `json:"discount"`
+	Tax                money.Money         `json:"tax"`
+	Charge             money.Money         `json:"charge"`
+	Rounding           money.Money         `json:"rounding"`
+	GrandTotal         money.Money         `json:"grand_total"`
+	Paid               money.Money         `json:"paid"`
+	PaymentStatus      PaymentStatus       `json:"payment_status"`
 	TransactionTime    *time.Time          `json:"-"`
 	TransactionTimeStr *string             `json:"transaction_time"`
 	DueDate            *time.Time          `json:"-"`
 	DueDateStr         *string             `json:"due_date"`
+	DateReady          *time.Time          `json:"-"`
+	DateReadyStr       *string             `json:"date_ready"`
 	DateTaken          *time.Time          `json:"-"`
 	DateTakenStr       *string             `json:"date_taken"`
 	PaymentMethod      PaymentMethod       `json:"payment_method"`
 	CashierName        string              `json:"cashier_name"`
+	HandedOverBy       string              `json:"handed_over_by,omitempty"`
+	InvoiceID          *int64              `json:"invoice_id"`
+	CreditApprovedBy   string              `json:"credit_approved_by,omitempty"`
+	CouponCode         string              `json:"coupon_code,omitempty"`
 	Details            []TransactionDetail `json:"details"`
+	Discounts          []DiscountLine      `json:"discounts"`
+	Taxes              []TaxLine           `json:"taxes"`
+	Payments           []Payment           `json:"payments"`
 }
 
+// TransactionDetail is a transaction line, its Subtotal includes the price of its add-ons.
+// ProductName and Price are a snapshot of the catalog product when the transaction was made,
+// ProductID is nil for old lines whose product could not be matched.
 type TransactionDetail struct {
-	ID          int64   `json:"id"`
-	ProductName string  `json:"product_name"`
-	ProductType string  `json:"product_type"`
-	Price       float64 `json:"price"`
-	Quantity    float64 `json:"quantity"`
-	Subtotal    float64 `json:"subtotal"`
+	ID          int64         `json:"id"`
+	ProductID   *int64        `json:"product_id"`
+	ProductName string        `json:"product_name"`
+	ProductType ProductType   `json:"product_type"`
+	Price       money.Money   `json:"price"`
+	Quantity    float64       `json:"quantity"`
+	Subtotal    money.Money   `json:"subtotal"`
+	Addons      []DetailAddon `json:"addons"`
+}
+
+// DetailAddon is an add-on selected on a transaction line
+type DetailAddon struct {
+	ID        int64       `json:"id"`
+	AddonID   int64       `json:"addon_id"`
+	Name      string      `json:"name"`
+	PriceType string      `json:"price_type"`
+	Price     money.Money `json:"price"`
+	Subtotal  money.Money `json:"subtotal"`
+}
+
+// DiscountLine is a promotion applied to a transaction
+type DiscountLine struct {
+	ID          int64       `json:"id"`
+	PromotionID int64       `json:"promotion_id"`
+	Name        string      `json:"name"`
+	ProductName string      `json:"product_name,omitempty"`
+	CouponCode  string      `json:"coupon_code,omitempty"`
+	Amount      money.Money `json:"amount"`
+}
+
+const (
+	TaxKindTax    = "tax"
+	TaxKindCharge = "charge"
+)
+
+// TaxLine is a tax or service charge of a transaction computed on Base, inclusive amounts are
+// already part of the subtotal while exclusive ones are added to the grand total
+type TaxLine struct {
+	Name      string      `json:"name"`
+	Kind      string      `json:"kind"`
+	Rate      float64     `json:"rate"`
+	Inclusive bool        `json:"inclusive"`
+	Base      money.Money `json:"base"`
+	Amount    money.Money `json:"amount"`
+}
+
+// Quote is the computed price of an order that has not been saved
+type Quote struct {
+	Details    []TransactionDetail `json:"details"`
+	Discounts  []DiscountLine      `json:"discounts"`
+	Taxes      []TaxLine           `json:"taxes"`
+	OutletCode string              `json:"outlet_code"`
+	Subtotal   money.Money         `json:"subtotal"`
+	Discount   money.Money         `json:"discount"`
+	Tax        money.Money         `json:"tax"`
+	Charge     money.Money         `json:"charge"`
+	Rounding   money.Money         `json:"rounding"`
+	GrandTotal money.Money         `json:"grand_total"`
+	DueDate    time.Time           `json:"-"`
+	DueDateStr string              `json:"due_date"`
+}
+
+type Status string
+
+const (
+	StatusReceived  Status = "received"
+	StatusWashing   Status = "washing"
+	StatusDrying    Status = "drying"
+	StatusIroning   Status = "ironing"
+	StatusPacked    Status = "packed"
+	StatusReady     Status = "ready"
+	StatusPickedUp  Status = "picked_up"
+	StatusCancelled Status = "cancelled"
+)
+
+// statusTransitions lists the statuses each status can change to, items that are not ironed
+// go straight from drying to packed. Picked up and cancelled orders are final.
+var statusTransitions = map[Status][]Status{
+	StatusReceived: {StatusWashing, StatusCancelled},
+	StatusWashing:  {StatusDrying, StatusCancelled},
+	StatusDrying:   {StatusIroning, StatusPacked, StatusCancelled},
+	StatusIroning:  {StatusPacked, StatusCancelled},
+	StatusPacked:   {StatusReady, StatusCancelled},
+	StatusReady:    {StatusPickedUp, StatusCancelled},
+}
+
+func (s Status) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok || s == StatusPickedUp || s == StatusCancelled
+}
+
+func (s Status) CanChangeTo(next Status) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange moves an order to Status, Actor is the staff member making the change
+type StatusChange struct {
+	TransactionID int64
+	Status        Status
+	Actor         string
+	Note          string
+}
+
+// StatusHistory is a recorded status change, FromStatus is empty for the status an order was created with
+type StatusHistory struct {
+	ID            int64     `json:"id"`
+	TransactionID int64     `json:"transaction_id"`
+	FromStatus    Status    `json:"from_status"`
+	ToStatus      Status    `json:"to_status"`
+	Actor         string    `json:"actor"`
+	Note          string    `json:"note"`
+	ChangedAt     time.Time `json:"-"`
+	ChangedAtStr  string    `json:"changed_at"`
 }
 
+const (
+	SortTransactionTime = "transaction_time"
+	SortDueDate         = "due_date"
+	SortGrandTotal      = "grand_total"
+	SortInvoiceNumber   = "invoice_number"
+)
+
+// Filter selects transactions, date ranges are whole days and include both ends
+type Filter struct {
+	// Statuses matches orders in any of the statuses, empty matches every status
+	Statuses   []Status
+	CustomerID *int64
+	// PaymentStatus matches orders in that payment status, empty matches every order
+	PaymentStatus PaymentStatus
+	// UnpaidOnly matches orders with an unpaid balance
+	UnpaidOnly bool
+	// OverdueOnly matches orders past their due date that are still not ready
+	OverdueOnly bool
+	DateFrom    *time.Time
+	DateTo      *time.Time
+	DueFrom     *time.Time
+	DueTo       *time.Time
+	// CashierName matches the cashier who took the order, case insensitive
+	CashierName string
+	// PaymentMethod matches orders with a payment of that method
+	PaymentMethod PaymentMethod
+	// Search matches orders whose invoice number, customer name or customer phone contains it
+	Search string
+	// Sort is one of the Sort constants, orders are listed from the newest when empty
+	Sort     string
+	SortDesc bool
+	// Limit of 0 returns every matching transaction
+	Limit  int
+	Offset int
+}
+
+// Pickup hands a ready order over to the customer, Payment settles the remaining balance and
+// must be either zero or exactly that balance
+type Pickup struct {
+	TransactionID    int64
+	HandedOverBy     string
+	Payment          money.Money
+	PaymentMethod    PaymentMethod
+	PaymentReference string
+}
+
+// Payment is an entry of the payment ledger of a transaction, an order can be paid in several
+// parts such as a cash down payment and the rest by QRIS at pickup. Reference identifies the
+// payment at the provider, e.g. a QRIS or bank transfer reference.
+type Payment struct {
+	ID            int64         `json:"id"`
+	TransactionID int64         `json:"transaction_id"`
+	Amount        money.Money   `json:"amount"`
+	Method        PaymentMethod `json:"method"`
+	Reference     string        `json:"reference,omitempty"`
+	CashierName   string        `json:"cashier_name"`
+	PaidAt        time.Time     `json:"-"`
+	PaidAtStr     string        `json:"paid_at"`
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusUnpaid        PaymentStatus = "unpaid"
+	PaymentStatusPartiallyPaid PaymentStatus = "partially_paid"
+	PaymentStatusPaid          PaymentStatus = "paid"
+)
+
+func (p PaymentStatus) Valid() bool {
+	return p == PaymentStatusUnpaid || p == PaymentStatusPartiallyPaid || p == PaymentStatusPaid
+}
+
+// PaymentStatusOf reports whether an order with the given paid amount and grand total is settled
+func PaymentStatusOf(paid, grandTotal money.Money) PaymentStatus {
+	if paid >= grandTotal {
+		return PaymentStatusPaid
+	}
+	if paid <= 0 {
+		return PaymentStatusUnpaid
+	}
+	return PaymentStatusPartiallyPaid
+}
+
+// ProductType is the service speed of a transaction line, it picks which product price applies
+type ProductType string
+
+const (
+	ProductTypeStandard        = "standard"
+	ProductTypeExpressToday    = "express_today"
+	ProductTypeExpressTomorrow = "express_tomorrow"
+)
+
 type PaymentMethod string
 
 const (
@@ -39,10 +270,54 @@ const (
 	PaymentMethodBCAMobile = "bca_mobile"
 )
 
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentMethodCash, PaymentMethodShopeePay, PaymentMethodQRIS, PaymentMethodBCAMobile:
+		return true
+	}
+	return false
+}
+
+var ErrCustomerBlacklisted = errors.New("Customer is blacklisted from unpaid orders")
+var ErrCreditLimitExceeded = errors.New("Unpaid balance would exceed the customer credit limit")
+var ErrEmptyTransaction = errors.New("Transaction must have at least one detail")
+var ErrUnknownProduct = errors.New("Unknown or inactive product")
+var ErrInvalidProductType = errors.New("Invalid product type, must be one of standard, express_today or express_tomorrow")
+var ErrUnknownOutlet = errors.New("Unknown outlet code")
+var ErrInvalidAddon = errors.New("Add-on is not available for this product")
+var ErrInvalidQuantity = errors.New("Quantity must be greater than zero")
+var ErrQuantityRule = errors.New("Quantity is below the product minimum or not a multiple of its quantity step")
+var ErrNotReady = errors.New("Order is not ready for pickup yet")
+var ErrUnpaidBalance = errors.New("Order still has an unpaid balance, settle it before pickup")
+var ErrInvalidPickup = errors.New("Pickup must record who handed the order over")
+var ErrInvalidPayment = errors.New("Payment must settle exactly the remaining balance")
+var ErrInvalidPaymentMethod = errors.New("Invalid payment method, must be one of cash, shopee_pay, qris or bca_mobile")
+var ErrInvalidPaymentAmount = errors.New("Payment amount must be greater than zero")
+var ErrMissingCashier = errors.New("Payment must record the cashier who received it")
+var ErrOverpayment = errors.New("Payments exceed the transaction grand total")
+var ErrInvalidPaymentStatus = errors.New("Invalid payment status, must be one of unpaid, partially_paid or paid")
+var ErrInvalidDateRange = errors.New("Date range must not end before it starts")
+var ErrInvalidStatus = errors.New("Invalid status, must be one of received, washing, drying, ironing, packed, ready, picked_up or cancelled")
+var ErrInvalidStatusChange = errors.New("Order can't change from its current status to the requested status")
+var ErrMissingActor = errors.New("Status changes must record who made them")
+var ErrPriceMismatch = errors.New("Transaction amounts do not match the product catalog prices")
+
 type Service interface {
-	MarkDateTaken(ctx context.Context, ID int64) error
-	NewTransaction(ctx context.Context, trans Transaction) error
+	// ChangeStatus moves the order along the status workflow, changing an order to its current
+	// status again changes nothing
+	ChangeStatus(ctx context.Context, change StatusChange) (Transaction, error)
+	// PickUp marks the order as taken, picking up a taken order again returns it unchanged
+	PickUp(ctx context.Context, pickup Pickup) (Transaction, error)
+	// AddPayment records a later payment of the order, a payment can't exceed the unpaid balance
+	AddPayment(ctx context.Context, payment Payment) (Transaction, error)
+	GetStatusHistory(ctx context.Context, ID int64) ([]StatusHistory, error)
+	GetTransactions(ctx context.Context, filter Filter) ([]Transaction, error)
+	// NewTransaction saves the transaction and returns it with its assigned ID and invoice number
+	NewTransaction(ctx context.Context, trans Transaction) (Transaction, error)
+	Quote(ctx context.Context, trans Transaction) (Quote, error)
 	GetTransactionDataByID(ctx context.Context, ID int64) (Transaction, error)
+	GetTransactionByInvoiceNumber(ctx context.Context, invoiceNumber string) (Transaction, error)
+	GetTransactionsByCustomerID(ctx context.Context, customerID int64) ([]Transaction, error)
 }
 
 var defaultService Service
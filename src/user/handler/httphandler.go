@@ -111,6 +111,66 @@ func (h *HTTPHandler) HandleAuthUser(w http.ResponseWriter, r *http.Request) {
 	httputil.WriteResponse(w, respJson)
 }
 
+// RequireRole only serves next to requests authenticated through HTTP basic auth
+// as a user with the given role, the authenticated user is available through user.FromContext
+func (h *HTTPHandler) RequireRole(role user.RoleID, next http.HandlerFunc) http.HandlerFunc {
+	return h.RequireAnyRole([]user.RoleID{role}, next)
+}
+
+// RequireAnyRole is RequireRole for endpoints shared by several roles
+func (h *HTTPHandler) RequireAnyRole(roles []user.RoleID, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), h.cfg.Timeout)
+		defer cancel()
+
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="laundry"`)
+			httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+				{
+					HttpStatus: http.StatusUnauthorized,
+					Title:      http.StatusText(http.StatusUnauthorized),
+					Detail:     "Authorization header is required",
+				},
+			})
+			return
+		}
+
+		u, err := h.svc.AuthUser(ctx, username, password)
+		if err != nil {
+			httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+				{
+					HttpStatus: http.StatusUnauthorized,
+					Title:      http.StatusText(http.StatusUnauthorized),
+					Detail:     err.Error(),
+				},
+			})
+			return
+		}
+		if !hasRole(u, roles) {
+			httputil.WriteErrorResponse(w, []httputil.ErrorResponse{
+				{
+					HttpStatus: http.StatusForbidden,
+					Title:      http.StatusText(http.StatusForbidden),
+					Detail:     user.ErrForbidden.Error(),
+				},
+			})
+			return
+		}
+
+		next(w, r.WithContext(user.NewContext(r.Context(), u)))
+	}
+}
+
+func hasRole(u user.User, roles []user.RoleID) bool {
+	for _, role := range roles {
+		if u.Role.RoleID == role {
+			return true
+		}
+	}
+	return false
+}
+
 func parseResponse(u user.User) UserResponse {
 	return UserResponse{
 		UserID:   u.UserID,
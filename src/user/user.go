@@ -13,6 +13,7 @@ type User struct {
 }
 
 var ErrAuthFailed = errors.New("Username atau password salah")
+var ErrForbidden = errors.New("Anda tidak memiliki akses untuk melakukan aksi ini")
 
 type Service interface {
 	AuthUser(ctx context.Context, username, password string) (User, error)
@@ -0,0 +1,121 @@
+// Package filestore provide a pluggable storage for uploaded files
+package filestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrInvalidName = errors.New("Invalid file name")
+var ErrNotFound = errors.New("File not found")
+
+// File is an opened stored file, it is seekable so it can be served with range and
+// conditional requests
+type File interface {
+	io.ReadSeeker
+	io.Closer
+	ModTime() time.Time
+}
+
+// Store keeps files under slash separated names such as "product/12/1697712345.png"
+type Store interface {
+	Save(ctx context.Context, name string, r io.Reader) error
+	Open(ctx context.Context, name string) (File, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Local stores files in a directory of the local filesystem
+type Local struct {
+	dir string
+}
+
+type localFile struct {
+	*os.File
+	modTime time.Time
+}
+
+func (f localFile) ModTime() time.Time {
+	return f.modTime
+}
+
+// Save writes to a temporary file first so readers never see a partially written file
+func (l *Local) Save(ctx context.Context, name string, r io.Reader) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".upload-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Open(ctx context.Context, name string) (File, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return localFile{File: f, modTime: info.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path maps name into the store directory, names escaping it are rejected
+func (l *Local) path(name string) (string, error) {
+	clean := filepath.Clean("/" + name)
+	if name == "" || clean == "/" || strings.Contains(name, "..") {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{
+		dir: dir,
+	}
+}
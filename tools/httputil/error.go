@@ -1,5 +1,7 @@
 package httputil
 
+import "net/http"
+
 type HttpStatus int
 
 type ErrorResponse struct {
@@ -11,3 +13,16 @@ type ErrorResponse struct {
 func (e ErrorResponse) Empty() bool {
 	return e == ErrorResponse{}
 }
+
+// NewErrorResponse describes err with the given http status
+func NewErrorResponse(status int, err error) ErrorResponse {
+	return ErrorResponse{
+		HttpStatus: HttpStatus(status),
+		Title:      http.StatusText(status),
+		Detail:     err.Error(),
+	}
+}
+
+func BadRequest(err error) ErrorResponse {
+	return NewErrorResponse(http.StatusBadRequest, err)
+}
@@ -0,0 +1,19 @@
+package httputil
+
+import "time"
+
+// FormatTime formats optional times of response fields, nil stays nil
+func FormatTime(t *time.Time, layout string) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(layout)
+	return &s
+}
+
+func DerefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
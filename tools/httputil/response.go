@@ -1,8 +1,11 @@
 package httputil
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 type Response struct {
@@ -30,3 +33,51 @@ func WriteResponse(w http.ResponseWriter, data json.RawMessage) {
 	w.Write(data)
 	return
 }
+
+// WriteDataResponse writes data with its count and the time spent processing the request
+func WriteDataResponse(w http.ResponseWriter, data interface{}, count int, elapsed time.Duration) {
+	resp := Response{
+		Data: data,
+		Meta: &Meta{
+			DataCount:   count,
+			ProcessTime: elapsed.Seconds(),
+		},
+	}
+
+	respJson, err := json.Marshal(resp)
+	if err != nil {
+		WriteErrorResponse(w, []ErrorResponse{
+			{
+				HttpStatus: http.StatusInternalServerError,
+				Title:      http.StatusText(http.StatusInternalServerError),
+				Detail:     "Failed to marshal API response",
+			},
+		})
+		return
+	}
+
+	WriteResponse(w, respJson)
+}
+
+// WriteSuccessResponse acknowledges a request that has no data to return
+func WriteSuccessResponse(w http.ResponseWriter, detail string, elapsed time.Duration) {
+	respData := struct {
+		Success bool   `json:"success"`
+		Detail  string `json:"detail"`
+	}{
+		Success: true,
+		Detail:  detail,
+	}
+	WriteDataResponse(w, respData, 1, elapsed)
+}
+
+// WriteCSVResponse writes records as a downloadable CSV file
+func WriteCSVResponse(w http.ResponseWriter, filename string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	writer := csv.NewWriter(w)
+	writer.WriteAll(records)
+	return
+}
@@ -2,11 +2,13 @@ package httputil
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 )
 
 const ContentTypeJson = "application/json"
 const ContentTypeForm = "application/x-www-form-urlencoded"
+const ContentTypeMultipart = "multipart/form-data"
 
 func ValidateRequest(r *http.Request, method, contentType string) ErrorResponse {
 	if r.Method != method {
@@ -16,7 +18,12 @@ func ValidateRequest(r *http.Request, method, contentType string) ErrorResponse
 			Detail:     fmt.Sprintf("Method %s not supported, only %s allowed", r.Method, method),
 		}
 	}
-	if r.Header.Get("Content-Type") != contentType {
+	// multipart requests carry their boundary as a content type parameter
+	mediaType := r.Header.Get("Content-Type")
+	if contentType == ContentTypeMultipart {
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	if mediaType != contentType {
 		return ErrorResponse{
 			HttpStatus: http.StatusUnsupportedMediaType,
 			Title:      http.StatusText(http.StatusUnsupportedMediaType),
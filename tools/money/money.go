@@ -0,0 +1,187 @@
+// Package money provide an exact amount type for prices and totals
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in whole rupiah
+type Money int64
+
+type RoundingMode string
+
+const (
+	RoundNearest RoundingMode = "nearest"
+	RoundUp      RoundingMode = "up"
+	RoundDown    RoundingMode = "down"
+)
+
+var ErrInvalidAmount = errors.New("Invalid money amount")
+
+// FromFloat converts a float amount, rounding half away from zero to the nearest rupiah
+func FromFloat(f float64) Money {
+	return Money(math.Round(f))
+}
+
+// Mul multiplies the amount by a quantity such as 2.7 kg, rounding to the nearest rupiah
+func (m Money) Mul(quantity float64) Money {
+	return Money(math.Round(float64(m) * quantity))
+}
+
+// Percent returns rate percent of the amount, rounding to the nearest rupiah
+func (m Money) Percent(rate float64) Money {
+	return Money(math.Round(float64(m) * rate / 100))
+}
+
+// Ratio returns the amount multiplied by num/den, rounding to the nearest rupiah
+func (m Money) Ratio(num, den Money) Money {
+	if den == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) * float64(num) / float64(den)))
+}
+
+// Round rounds the amount to a multiple of unit, a unit of 0 or 1 leaves it unchanged
+func (m Money) Round(unit int64, mode RoundingMode) Money {
+	if unit <= 1 {
+		return m
+	}
+	u := Money(unit)
+	rem := m % u
+	if rem == 0 {
+		return m
+	}
+	down := m - rem
+	if rem < 0 {
+		down -= u
+		rem += u
+	}
+	switch mode {
+	case RoundUp:
+		return down + u
+	case RoundDown:
+		return down
+	}
+	if rem*2 >= u {
+		return down + u
+	}
+	return down
+}
+
+func Min(a, b Money) Money {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (m Money) Float64() float64 {
+	return float64(m)
+}
+
+func (m Money) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+// Parse reads a decimal amount such as "87500" or "87500.00", fractions are rounded to the
+// nearest rupiah
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if strings.ContainsAny(intPart+fracPart, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, ErrInvalidAmount
+		}
+		if neg {
+			f = -f
+		}
+		return FromFloat(f), nil
+	}
+
+	v, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	for _, c := range fracPart {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidAmount
+		}
+	}
+	if len(fracPart) > 0 && fracPart[0] >= '5' {
+		v++
+	}
+	if neg {
+		v = -v
+	}
+	return Money(v), nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	v, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return fmt.Errorf("%s: %s", ErrInvalidAmount.Error(), s)
+	}
+	*m = v
+	return nil
+}
+
+// Scan reads a postgres numeric column
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case int64:
+		*m = Money(v)
+		return nil
+	case float64:
+		*m = FromFloat(v)
+		return nil
+	case []byte:
+		res, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*m = res
+		return nil
+	case string:
+		res, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*m = res
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into money", src)
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
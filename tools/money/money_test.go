@@ -0,0 +1,119 @@
+package money
+
+import "testing"
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		m        Money
+		quantity float64
+		want     Money
+	}{
+		{7000, 2, 14000},
+		{7000, 2.7, 18900},
+		{3333, 1.5, 5000},
+		{-7000, 2.5, -17500},
+		{7000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Mul(tt.quantity); got != tt.want {
+			t.Errorf("%d.Mul(%v) = %d, want %d", tt.m, tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		m    Money
+		rate float64
+		want Money
+	}{
+		{100000, 11, 11000},
+		{12345, 10, 1235},
+		{12345, 2.5, 309},
+		{-12345, 10, -1235},
+		{100000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Percent(tt.rate); got != tt.want {
+			t.Errorf("%d.Percent(%v) = %d, want %d", tt.m, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		m, num, den Money
+		want        Money
+	}{
+		{100000, 1, 3, 33333},
+		{100000, 2, 3, 66667},
+		{-100000, 2, 3, -66667},
+		{100000, 3, 3, 100000},
+		{100000, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Ratio(tt.num, tt.den); got != tt.want {
+			t.Errorf("%d.Ratio(%d, %d) = %d, want %d", tt.m, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		m    Money
+		unit int64
+		mode RoundingMode
+		want Money
+	}{
+		{12345, 100, RoundNearest, 12300},
+		{12350, 100, RoundNearest, 12400},
+		{12351, 100, RoundNearest, 12400},
+		{12300, 100, RoundNearest, 12300},
+		{12301, 100, RoundUp, 12400},
+		{12300, 100, RoundUp, 12300},
+		{12399, 100, RoundDown, 12300},
+		{12345, 1, RoundUp, 12345},
+		{12345, 0, RoundDown, 12345},
+		{-12345, 100, RoundNearest, -12300},
+		{-12350, 100, RoundNearest, -12300},
+		{-12351, 100, RoundNearest, -12400},
+		{-12301, 100, RoundUp, -12300},
+		{-12301, 100, RoundDown, -12400},
+		{-12300, 100, RoundDown, -12300},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Round(tt.unit, tt.mode); got != tt.want {
+			t.Errorf("%d.Round(%d, %s) = %d, want %d", tt.m, tt.unit, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Money
+		wantErr bool
+	}{
+		{"87500", 87500, false},
+		{"87500.00", 87500, false},
+		{"87500.5", 87501, false},
+		{"87500.49", 87500, false},
+		{"-87500.5", -87501, false},
+		{"+1000", 1000, false},
+		{".5", 1, false},
+		{"1e3", 1000, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"10.5x", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -9,14 +9,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"gopkg.in/yaml.v2"
 )
 
 type database struct {
 	name        string
+	masterDSN   string
 	replication databaseReplication
 }
 
@@ -73,7 +75,8 @@ func InitPostgresqlConfig(ctx context.Context, basepath string) error {
 			slaves = append(slaves, slave)
 		}
 		databaseMap[db.Name] = database{
-			name: db.Name,
+			name:      db.Name,
+			masterDSN: db.Master,
 			replication: databaseReplication{
 				master: master,
 				slaves: slaves,
@@ -116,3 +119,29 @@ func GetDB(dbName, replication string) (*sqlx.DB, error) {
 	}
 	return nil, errDBNotExist
 }
+
+// Listen calls onNotify for every notification sent on channel of the master of dbName. It is
+// also called after the connection is reestablished since notifications may have been missed.
+func Listen(dbName, channel string, onNotify func()) error {
+	globalLock.RLock()
+	db, ok := databaseMap[dbName]
+	globalLock.RUnlock()
+	if !ok {
+		return errDBNotExist
+	}
+
+	listener := pq.NewListener(db.masterDSN, 10*time.Second, time.Minute, nil)
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	// a nil notification is sent after a reconnect
+	go func() {
+		for range listener.Notify {
+			onNotify()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package querybuilder provide parameterized where, order by and pagination clauses for postgres queries
+package querybuilder
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corneliusdavid97/laundry-go/tools/money"
+)
+
+var ErrInvalidParam = errors.New("Invalid filter parameter")
+
+// Builder collects conditions and their args, every ? in a condition becomes a numbered
+// postgres placeholder so values are never formatted into the query
+type Builder struct {
+	conds  []string
+	args   []interface{}
+	orders []string
+	limit  int
+	offset int
+}
+
+// New creates a builder for a query that already uses args as its first placeholders
+func New(args ...interface{}) *Builder {
+	return &Builder{
+		args: args,
+	}
+}
+
+// Where adds a condition joined with and, cond must contain one ? per arg
+func (b *Builder) Where(cond string, args ...interface{}) *Builder {
+	parts := strings.Split(cond, "?")
+	var sb strings.Builder
+	for i, part := range parts {
+		sb.WriteString(part)
+		if i < len(parts)-1 {
+			b.args = append(b.args, args[i])
+			sb.WriteString("$" + strconv.Itoa(len(b.args)))
+		}
+	}
+	b.conds = append(b.conds, sb.String())
+	return b
+}
+
+// OrderBy adds a sort column, column must come from a fixed list and never from the request
+func (b *Builder) OrderBy(column string, desc bool) *Builder {
+	if desc {
+		column += " desc"
+	}
+	b.orders = append(b.orders, column)
+	return b
+}
+
+// Page limits the result, a limit of 0 returns every row
+func (b *Builder) Page(limit, offset int) *Builder {
+	b.limit = limit
+	b.offset = offset
+	return b
+}
+
+// Build returns the conditions followed by the order by and limit clauses, to be placed after
+// the where keyword of a query, along with the args of the whole query
+func (b *Builder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	if len(b.conds) == 0 {
+		sb.WriteString("true")
+	}
+	sb.WriteString(strings.Join(b.conds, " and "))
+
+	if len(b.orders) > 0 {
+		sb.WriteString(" order by " + strings.Join(b.orders, ", "))
+	}
+	args := b.args
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		sb.WriteString(" limit $" + strconv.Itoa(len(args)))
+	}
+	if b.offset > 0 {
+		args = append(args, b.offset)
+		sb.WriteString(" offset $" + strconv.Itoa(len(args)))
+	}
+	return sb.String(), args
+}
+
+// Like escapes s for a like pattern matching any text containing s
+func Like(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// Params reads filter values of a url query, the first malformed value is kept and returned by Err
+type Params struct {
+	values url.Values
+	err    error
+}
+
+func NewParams(values url.Values) *Params {
+	return &Params{
+		values: values,
+	}
+}
+
+func (p *Params) Err() error {
+	return p.err
+}
+
+func (p *Params) String(key string) string {
+	return strings.TrimSpace(p.values.Get(key))
+}
+
+func (p *Params) Bool(key string) *bool {
+	s := p.String(key)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		p.fail(key, s)
+		return nil
+	}
+	return &b
+}
+
+func (p *Params) Int64(key string) *int64 {
+	s := p.String(key)
+	if s == "" {
+		return nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		p.fail(key, s)
+		return nil
+	}
+	return &i
+}
+
+func (p *Params) Money(key string) *money.Money {
+	s := p.String(key)
+	if s == "" {
+		return nil
+	}
+	m, err := money.Parse(s)
+	if err != nil {
+		p.fail(key, s)
+		return nil
+	}
+	return &m
+}
+
+// Date reads a day in 2006-01-02 form, it starts at midnight local time
+func (p *Params) Date(key string) *time.Time {
+	s := p.String(key)
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		p.fail(key, s)
+		return nil
+	}
+	return &d
+}
+
+// Int returns a non negative integer such as a limit or offset, 0 when the key is absent
+func (p *Params) Int(key string) int {
+	s := p.String(key)
+	if s == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		p.fail(key, s)
+		return 0
+	}
+	return i
+}
+
+// OneOf returns the value of key when it is one of allowed
+func (p *Params) OneOf(key string, allowed ...string) string {
+	s := p.String(key)
+	if s == "" {
+		return ""
+	}
+	for _, a := range allowed {
+		if s == a {
+			return s
+		}
+	}
+	p.fail(key, s)
+	return ""
+}
+
+// Sort reads a sort field in "field" or "-field" form, the minus sign sorts descending
+func (p *Params) Sort(key string, allowed ...string) (string, bool) {
+	s := p.String(key)
+	desc := strings.HasPrefix(s, "-")
+	field := strings.TrimPrefix(s, "-")
+	if field == "" {
+		return "", false
+	}
+	for _, a := range allowed {
+		if field == a {
+			return field, desc
+		}
+	}
+	p.fail(key, s)
+	return "", false
+}
+
+func (p *Params) fail(key, value string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s=%s", ErrInvalidParam, key, value)
+	}
+}
@@ -0,0 +1,164 @@
+package querybuilder
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		b         *Builder
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "empty",
+			b:         New(),
+			wantQuery: "true",
+		},
+		{
+			name:      "numbers placeholders in order",
+			b:         New().Where("a=?", 1).Where("b between ? and ?", 2, 3),
+			wantQuery: "a=$1 and b between $2 and $3",
+			wantArgs:  []interface{}{1, 2, 3},
+		},
+		{
+			name:      "continues after the query args",
+			b:         New("x", "y").Where("a=?", 1),
+			wantQuery: "a=$3",
+			wantArgs:  []interface{}{"x", "y", 1},
+		},
+		{
+			name:      "condition without args",
+			b:         New().Where("a is null").Where("b=?", 1),
+			wantQuery: "a is null and b=$1",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name:      "order and page",
+			b:         New().Where("a=?", 1).OrderBy("name", false).OrderBy("id", true).Page(10, 20),
+			wantQuery: "a=$1 order by name, id desc limit $2 offset $3",
+			wantArgs:  []interface{}{1, 10, 20},
+		},
+		{
+			name:      "offset without limit",
+			b:         New().Page(0, 5),
+			wantQuery: "true offset $1",
+			wantArgs:  []interface{}{5},
+		},
+	}
+	for _, tt := range tests {
+		query, args := tt.b.Build()
+		if query != tt.wantQuery {
+			t.Errorf("%s: query = %q, want %q", tt.name, query, tt.wantQuery)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestLike(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"shirt", "%shirt%"},
+		{"", "%%"},
+		{"50%", `%50\%%`},
+		{"bed_cover", `%bed\_cover%`},
+		{`a\b`, `%a\\b%`},
+		{`\%_`, `%\\\%\_%`},
+	}
+	for _, tt := range tests {
+		if got := Like(tt.s); got != tt.want {
+			t.Errorf("Like(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	values := url.Values{
+		"active":   {"true"},
+		"id":       {" 42 "},
+		"price":    {"87500.00"},
+		"from":     {"2021-03-01"},
+		"limit":    {"10"},
+		"category": {"kiloan"},
+		"sort":     {"-name"},
+	}
+	p := NewParams(values)
+
+	if b := p.Bool("active"); b == nil || !*b {
+		t.Errorf("Bool(active) = %v, want true", b)
+	}
+	if i := p.Int64("id"); i == nil || *i != 42 {
+		t.Errorf("Int64(id) = %v, want 42", i)
+	}
+	if m := p.Money("price"); m == nil || *m != 87500 {
+		t.Errorf("Money(price) = %v, want 87500", m)
+	}
+	if d := p.Date("from"); d == nil || d.Format("2006-01-02 15:04") != "2021-03-01 00:00" {
+		t.Errorf("Date(from) = %v, want 2021-03-01 00:00", d)
+	}
+	if i := p.Int("limit"); i != 10 {
+		t.Errorf("Int(limit) = %d, want 10", i)
+	}
+	if s := p.OneOf("category", "kiloan", "satuan"); s != "kiloan" {
+		t.Errorf("OneOf(category) = %q, want kiloan", s)
+	}
+	if field, desc := p.Sort("sort", "id", "name"); field != "name" || !desc {
+		t.Errorf("Sort(sort) = %q, %v, want name, true", field, desc)
+	}
+	if p.Bool("missing") != nil || p.Int64("missing") != nil || p.Int("missing") != 0 {
+		t.Errorf("missing keys should read as absent")
+	}
+	if err := p.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestParamsErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		read    func(p *Params)
+		wantErr string
+	}{
+		{"bool", "active=foo", func(p *Params) { p.Bool("active") }, "Invalid filter parameter: active=foo"},
+		{"int64", "id=1x", func(p *Params) { p.Int64("id") }, "Invalid filter parameter: id=1x"},
+		{"money", "price=abc", func(p *Params) { p.Money("price") }, "Invalid filter parameter: price=abc"},
+		{"date", "from=2021-13-01", func(p *Params) { p.Date("from") }, "Invalid filter parameter: from=2021-13-01"},
+		{"negative int", "limit=-1", func(p *Params) { p.Int("limit") }, "Invalid filter parameter: limit=-1"},
+		{"one of", "category=foo", func(p *Params) { p.OneOf("category", "kiloan", "satuan") }, "Invalid filter parameter: category=foo"},
+		{"sort", "sort=-price", func(p *Params) { p.Sort("sort", "id", "name") }, "Invalid filter parameter: sort=-price"},
+		{
+			name:  "keeps the first error",
+			query: "active=foo&id=bar",
+			read: func(p *Params) {
+				p.Bool("active")
+				p.Int64("id")
+			},
+			wantErr: "Invalid filter parameter: active=foo",
+		},
+	}
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewParams(values)
+		tt.read(p)
+		err = p.Err()
+		if !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("%s: Err() = %v, want ErrInvalidParam", tt.name, err)
+			continue
+		}
+		if err.Error() != tt.wantErr {
+			t.Errorf("%s: Err() = %q, want %q", tt.name, err.Error(), tt.wantErr)
+		}
+	}
+}